@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"categories-api/middleware"
+	"categories-api/models"
+	"categories-api/repositories"
+	"categories-api/utils"
+
+	"github.com/spf13/viper"
+)
+
+type contextKey struct{}
+
+// Middleware authenticates every request and stores the caller's tenant in
+// the request context. Tenants authenticate with their API key in the
+// X-API-Key header or as a Bearer token; /tenants is reserved for the
+// ADMIN_API_KEY. Requests without a key fall back to DEFAULT_TENANT_ID when
+// it is set, which keeps single-merchant deployments working unchanged.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/swagger") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		apiKey := apiKeyFromRequest(r)
+
+		if r.URL.Path == "/tenants" || strings.HasPrefix(r.URL.Path, "/tenants/") {
+			adminKey := viper.GetString("ADMIN_API_KEY")
+			if adminKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(adminKey)) != 1 {
+				utils.WriteError(w, http.StatusForbidden, models.ErrCodeForbidden, "admin API key required", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var tenantID int
+		if apiKey == "" {
+			tenantID = viper.GetInt("DEFAULT_TENANT_ID")
+			if tenantID == 0 {
+				utils.WriteError(w, http.StatusUnauthorized, models.ErrCodeUnauthorized, "API key required", nil)
+				return
+			}
+		} else {
+			id, err := repositories.GetTenantIDByAPIKey(r.Context(), apiKey)
+			switch {
+			case errors.Is(err, repositories.ErrTenantInactive):
+				utils.WriteError(w, http.StatusForbidden, models.ErrCodeForbidden, err.Error(), nil)
+				return
+			case errors.Is(err, sql.ErrNoRows):
+				utils.WriteError(w, http.StatusUnauthorized, models.ErrCodeUnauthorized, "invalid API key", nil)
+				return
+			case err != nil:
+				// The key could not be checked, e.g. the database is down;
+				// it is not the client's key that is wrong.
+				slog.ErrorContext(r.Context(), "api key lookup failed",
+					slog.String("request_id", middleware.RequestIDFrom(r.Context())),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("error", err.Error()),
+				)
+				utils.WriteError(w, http.StatusInternalServerError, models.ErrCodeInternal, "internal server error", nil)
+				return
+			}
+			tenantID = id
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, tenantID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// TenantID returns the tenant authenticated by Middleware, or 0.
+func TenantID(r *http.Request) int {
+	id, _ := r.Context().Value(contextKey{}).(int)
+	return id
+}
+
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"log"
+	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/spf13/viper"
@@ -10,7 +11,16 @@ import (
 
 var DB *sql.DB
 
-func InitDb(connectionString string) (*sql.DB, error) {
+// PoolConfig sizes the connection pool. Zero values leave the database/sql
+// default in place (unlimited open connections, 2 idle, no lifetime limit).
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func InitDb(connectionString string, pool PoolConfig) (*sql.DB, error) {
 	// Open Database
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
@@ -24,19 +34,29 @@ func InitDb(connectionString string) (*sql.DB, error) {
 	}
 
 	// Set connection pool settings (optional tapi recommended)
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
+	if pool.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
 
 	log.Println("Database connected successfully")
 	return db, nil
 }
 
-func InitDB() error {
+func InitDB(pool PoolConfig) error {
 	dbConn := viper.GetString("DB_CONN")
 	if dbConn == "" {
 		log.Fatal("DB_CONN is not set in environment variables")
 	}
-	db, err := InitDb(dbConn)
+	db, err := InitDb(dbConn, pool)
 	if err != nil {
 		return err
 	}
@@ -47,3 +67,12 @@ func InitDB() error {
 func GetDB() *sql.DB {
 	return DB
 }
+
+// Close closes the pool at shutdown. Queries already running may finish;
+// new ones fail.
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Close()
+}
@@ -0,0 +1,6 @@
+ALTER TABLE transactions
+    ADD COLUMN payment_method VARCHAR(20) NOT NULL DEFAULT 'cash',
+    ADD COLUMN paid_amount INT NOT NULL DEFAULT 0,
+    ADD COLUMN change_amount INT NOT NULL DEFAULT 0;
+
+UPDATE transactions SET paid_amount = total_amount WHERE paid_amount = 0;
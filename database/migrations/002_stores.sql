@@ -0,0 +1,40 @@
+CREATE TABLE stores (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    address TEXT,
+    phone VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO stores (name) VALUES ('Outlet Pusat');
+
+CREATE TABLE store_stocks (
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    stock INT NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    PRIMARY KEY (store_id, product_id)
+);
+
+-- Existing stock moves into the first store.
+INSERT INTO store_stocks (store_id, product_id, stock) SELECT 1, id, stock FROM products;
+
+CREATE TABLE stock_transfers (
+    id SERIAL PRIMARY KEY,
+    from_store_id INT NOT NULL REFERENCES stores(id),
+    to_store_id INT NOT NULL REFERENCES stores(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'in_transit',
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    received_at TIMESTAMP
+);
+
+CREATE TABLE stock_transfer_items (
+    id SERIAL PRIMARY KEY,
+    transfer_id INT NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0)
+);
+
+ALTER TABLE transactions ADD COLUMN store_id INT NOT NULL DEFAULT 1 REFERENCES stores(id);
+ALTER TABLE transactions ALTER COLUMN store_id DROP DEFAULT;
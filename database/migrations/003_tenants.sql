@@ -0,0 +1,29 @@
+CREATE TABLE tenants (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    api_key_hash CHAR(64) NOT NULL UNIQUE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- All existing data belongs to tenant 1.
+INSERT INTO tenants (name, api_key_hash) VALUES ('Default', repeat('-', 64));
+
+ALTER TABLE stores ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE categories ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE products ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE stock_transfers ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE transactions ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+
+ALTER TABLE stores ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE categories ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE products ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE stock_transfers ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE transactions ALTER COLUMN tenant_id DROP DEFAULT;
+
+CREATE INDEX idx_stores_tenant ON stores (tenant_id);
+CREATE INDEX idx_categories_tenant ON categories (tenant_id);
+CREATE INDEX idx_products_tenant ON products (tenant_id);
+CREATE INDEX idx_stock_transfers_tenant ON stock_transfers (tenant_id);
+CREATE INDEX idx_transactions_tenant_created ON transactions (tenant_id, created_at);
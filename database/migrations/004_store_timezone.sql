@@ -0,0 +1,2 @@
+-- IANA zone name, e.g. 'Asia/Jakarta'. Empty means STORE_TIMEZONE from config.
+ALTER TABLE stores ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '';
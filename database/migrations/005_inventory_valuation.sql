@@ -0,0 +1,15 @@
+ALTER TABLE products ADD COLUMN cost INT NOT NULL DEFAULT 0 CHECK (cost >= 0);
+
+CREATE TABLE stock_receipts (
+    id SERIAL PRIMARY KEY,
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_stock_receipts_store_product ON stock_receipts (store_id, product_id, received_at);
+
+-- The arrival date of existing stock is unknown; it starts aging today.
+INSERT INTO stock_receipts (store_id, product_id, quantity)
+SELECT store_id, product_id, stock FROM store_stocks WHERE stock > 0;
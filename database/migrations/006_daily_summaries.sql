@@ -0,0 +1,43 @@
+-- Daily sales summaries, maintained on checkout and rebuilt with
+-- "go run . rebuild-summaries". Dates are local days in the store's timezone.
+CREATE TABLE daily_sales (
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    sales_date DATE NOT NULL,
+    payment_method VARCHAR(20) NOT NULL,
+    total_revenue BIGINT NOT NULL DEFAULT 0,
+    total_transactions INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (store_id, sales_date, payment_method)
+);
+
+CREATE TABLE daily_product_sales (
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    sales_date DATE NOT NULL,
+    product_id INT NOT NULL,
+    category_id INT NOT NULL,
+    payment_method VARCHAR(20) NOT NULL,
+    qty_sold INT NOT NULL DEFAULT 0,
+    revenue BIGINT NOT NULL DEFAULT 0,
+    total_transactions INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (store_id, sales_date, product_id, payment_method)
+);
+
+CREATE TABLE daily_category_sales (
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    sales_date DATE NOT NULL,
+    category_id INT NOT NULL,
+    payment_method VARCHAR(20) NOT NULL,
+    qty_sold INT NOT NULL DEFAULT 0,
+    revenue BIGINT NOT NULL DEFAULT 0,
+    total_transactions INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (store_id, sales_date, category_id, payment_method)
+);
+
+CREATE INDEX idx_daily_sales_tenant_date ON daily_sales (tenant_id, sales_date);
+CREATE INDEX idx_daily_product_sales_tenant_date ON daily_product_sales (tenant_id, sales_date);
+CREATE INDEX idx_daily_category_sales_tenant_date ON daily_category_sales (tenant_id, sales_date);
+
+-- Fill the summaries for existing transactions afterwards with:
+--   go run . rebuild-summaries
@@ -0,0 +1,23 @@
+-- End-of-day reports per store. Rows are never updated; corrections are
+-- inserted as the next revision.
+CREATE TABLE report_snapshots (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    report_date DATE NOT NULL,
+    revision INT NOT NULL,
+    report JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (store_id, report_date, revision)
+);
+
+CREATE FUNCTION forbid_report_snapshot_update() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'report snapshots are immutable, insert a new revision instead';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER report_snapshots_immutable BEFORE UPDATE ON report_snapshots
+    FOR EACH ROW EXECUTE FUNCTION forbid_report_snapshot_update();
+
+CREATE INDEX idx_report_snapshots_tenant_date ON report_snapshots (tenant_id, report_date);
@@ -0,0 +1,2 @@
+ALTER TABLE products ADD COLUMN sku VARCHAR(64);
+ALTER TABLE products ADD CONSTRAINT products_tenant_id_sku_key UNIQUE (tenant_id, sku);
@@ -0,0 +1,7 @@
+-- Requires a role allowed to create extensions (pg_trgm ships with Postgres).
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_products_search ON products USING gin (to_tsvector('simple', name || ' ' || COALESCE(sku, '')));
+CREATE INDEX idx_products_name_trgm ON products USING gin (name gin_trgm_ops);
+CREATE INDEX idx_products_sku_trgm ON products USING gin (sku gin_trgm_ops);
+CREATE INDEX idx_categories_name_search ON categories USING gin (to_tsvector('simple', name));
@@ -0,0 +1,3 @@
+ALTER TABLE products ADD COLUMN barcode VARCHAR(64);
+
+CREATE INDEX idx_products_tenant_barcode ON products (tenant_id, barcode);
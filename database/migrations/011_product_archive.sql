@@ -0,0 +1,2 @@
+-- Products with sales or transfer history are archived instead of deleted.
+ALTER TABLE products ADD COLUMN archived_at TIMESTAMP;
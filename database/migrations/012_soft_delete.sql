@@ -0,0 +1,3 @@
+-- Deleted categories and products are kept until "go run . purge-deleted".
+ALTER TABLE categories ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP;
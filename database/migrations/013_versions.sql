@@ -0,0 +1,3 @@
+-- Row versions for ETag / If-Match; every update bumps them.
+ALTER TABLE categories ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE products ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,6 @@
+-- Category of the product at the time of sale, so reports keep sales in the
+-- category they were made in after the product moves. Existing sales get the
+-- product's current category; run "go run . rebuild-summaries" afterwards.
+ALTER TABLE transaction_details ADD COLUMN category_id INT;
+UPDATE transaction_details td SET category_id = p.categories_id FROM products p WHERE td.product_id = p.id;
+ALTER TABLE transaction_details ALTER COLUMN category_id SET NOT NULL;
@@ -0,0 +1,15 @@
+-- Write timestamp defaults in UTC whatever the server's timezone setting.
+-- Rows written before this ran hold the server's local time; if it was not
+-- UTC, convert them per column, e.g. for Asia/Jakarta:
+--   UPDATE transactions SET created_at = created_at AT TIME ZONE 'Asia/Jakarta' AT TIME ZONE 'UTC';
+-- and run "go run . rebuild-summaries" afterwards.
+ALTER TABLE tenants ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE tenants ALTER COLUMN updated_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE stores ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE stores ALTER COLUMN updated_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE categories ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE categories ALTER COLUMN updated_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE stock_receipts ALTER COLUMN received_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE stock_transfers ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE transactions ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE report_snapshots ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
@@ -0,0 +1,12 @@
+-- Receipt dates of the stock on its way between stores, so a received
+-- transfer keeps its age instead of starting again at zero days.
+-- Transfers already in transit have no lots; they age from arrival.
+CREATE TABLE stock_transfer_lots (
+    id SERIAL PRIMARY KEY,
+    transfer_id INT NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    received_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_stock_transfer_lots_transfer ON stock_transfer_lots (transfer_id);
@@ -0,0 +1,75 @@
+-- Optional row-level security, enforced in addition to the tenant_id filters
+-- in the repository queries. Apply after schema.sql and start the API with
+-- DB_ROW_LEVEL_SECURITY=true so every connection carries app.tenant_id.
+-- Run migrations and admin tasks as a role with BYPASSRLS.
+
+ALTER TABLE stores ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stores FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON stores
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE categories ENABLE ROW LEVEL SECURITY;
+ALTER TABLE categories FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON categories
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE products ENABLE ROW LEVEL SECURITY;
+ALTER TABLE products FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON products
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE stock_transfers ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stock_transfers FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON stock_transfers
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE transactions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE transactions FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON transactions
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE daily_sales ENABLE ROW LEVEL SECURITY;
+ALTER TABLE daily_sales FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON daily_sales
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE daily_product_sales ENABLE ROW LEVEL SECURITY;
+ALTER TABLE daily_product_sales FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON daily_product_sales
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE daily_category_sales ENABLE ROW LEVEL SECURITY;
+ALTER TABLE daily_category_sales FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON daily_category_sales
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE report_snapshots ENABLE ROW LEVEL SECURITY;
+ALTER TABLE report_snapshots FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON report_snapshots
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+-- Child tables follow their parent row, which is itself filtered by policy.
+ALTER TABLE store_stocks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE store_stocks FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON store_stocks
+    USING (EXISTS (SELECT 1 FROM stores s WHERE s.id = store_id));
+
+ALTER TABLE stock_receipts ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stock_receipts FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON stock_receipts
+    USING (EXISTS (SELECT 1 FROM stores s WHERE s.id = store_id));
+
+ALTER TABLE stock_transfer_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stock_transfer_items FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON stock_transfer_items
+    USING (EXISTS (SELECT 1 FROM stock_transfers st WHERE st.id = transfer_id));
+
+ALTER TABLE stock_transfer_lots ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stock_transfer_lots FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON stock_transfer_lots
+    USING (EXISTS (SELECT 1 FROM stock_transfers st WHERE st.id = transfer_id));
+
+ALTER TABLE transaction_details ENABLE ROW LEVEL SECURITY;
+ALTER TABLE transaction_details FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON transaction_details
+    USING (EXISTS (SELECT 1 FROM transactions t WHERE t.id = transaction_id));
@@ -0,0 +1,207 @@
+-- Full schema for a fresh database. Existing databases are upgraded with the
+-- scripts in database/migrations, applied in order.
+--
+-- Timestamps are UTC wall-clock times. Defaults and writes use
+-- now() AT TIME ZONE 'UTC', so they do not depend on the server's timezone.
+
+-- Trigram similarity for typo-tolerant product search.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE tenants (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    api_key_hash CHAR(64) NOT NULL UNIQUE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'UTC')
+);
+
+-- Tenant 1 has no API key; serve it with DEFAULT_TENANT_ID=1 for a
+-- single-merchant deployment, or rotate its key via POST /tenants/1/rotate-key.
+INSERT INTO tenants (name, api_key_hash) VALUES ('Default', repeat('-', 64));
+
+CREATE TABLE stores (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    name VARCHAR(255) NOT NULL,
+    address TEXT,
+    phone VARCHAR(50),
+    timezone VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'UTC')
+);
+
+INSERT INTO stores (tenant_id, name) VALUES (1, 'Outlet Pusat');
+
+CREATE TABLE categories (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'UTC'),
+    -- Bumped on every write; served as the ETag for If-Match.
+    version INT NOT NULL DEFAULT 1,
+    -- Soft delete; rows are removed by "go run . purge-deleted".
+    deleted_at TIMESTAMP
+);
+
+CREATE TABLE products (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    sku VARCHAR(64),
+    name VARCHAR(255) NOT NULL,
+    price INT NOT NULL,
+    cost INT NOT NULL DEFAULT 0 CHECK (cost >= 0),
+    stock INT NOT NULL,
+    categories_id INT NOT NULL,
+    barcode VARCHAR(64),
+    -- Archived products are hidden from the catalog but keep their history.
+    archived_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    version INT NOT NULL DEFAULT 1,
+    FOREIGN KEY (categories_id) REFERENCES categories(id) ON DELETE CASCADE,
+    UNIQUE (tenant_id, sku)
+);
+
+CREATE TABLE store_stocks (
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    stock INT NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    PRIMARY KEY (store_id, product_id)
+);
+
+-- Every stock increase per store, used to age the stock on hand.
+CREATE TABLE stock_receipts (
+    id SERIAL PRIMARY KEY,
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    received_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'UTC')
+);
+
+CREATE TABLE stock_transfers (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    from_store_id INT NOT NULL REFERENCES stores(id),
+    to_store_id INT NOT NULL REFERENCES stores(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'in_transit',
+    note TEXT,
+    created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'UTC'),
+    received_at TIMESTAMP
+);
+
+CREATE TABLE stock_transfer_items (
+    id SERIAL PRIMARY KEY,
+    transfer_id INT NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0)
+);
+
+-- The stock_receipts dates of the stock in transit, carried over to the
+-- destination store when the transfer is received.
+CREATE TABLE stock_transfer_lots (
+    id SERIAL PRIMARY KEY,
+    transfer_id INT NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    received_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE transactions (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    store_id INT NOT NULL REFERENCES stores(id),
+    total_amount INT NOT NULL,
+    payment_method VARCHAR(20) NOT NULL DEFAULT 'cash',
+    paid_amount INT NOT NULL DEFAULT 0,
+    change_amount INT NOT NULL DEFAULT 0,
+    status VARCHAR(20) DEFAULT 'completed',
+    created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'UTC')
+);
+
+CREATE TABLE transaction_details (
+    id SERIAL PRIMARY KEY,
+    transaction_id INT REFERENCES transactions(id) ON DELETE CASCADE,
+    product_id INT REFERENCES products(id),
+    -- Category of the product at the time of sale.
+    category_id INT NOT NULL,
+    quantity INT NOT NULL,
+    subtotal INT NOT NULL
+);
+
+-- Daily sales summaries, maintained on checkout and rebuilt with
+-- "go run . rebuild-summaries". Dates are local days in the store's timezone.
+CREATE TABLE daily_sales (
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    sales_date DATE NOT NULL,
+    payment_method VARCHAR(20) NOT NULL,
+    total_revenue BIGINT NOT NULL DEFAULT 0,
+    total_transactions INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (store_id, sales_date, payment_method)
+);
+
+CREATE TABLE daily_product_sales (
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    sales_date DATE NOT NULL,
+    product_id INT NOT NULL,
+    category_id INT NOT NULL,
+    payment_method VARCHAR(20) NOT NULL,
+    qty_sold INT NOT NULL DEFAULT 0,
+    revenue BIGINT NOT NULL DEFAULT 0,
+    total_transactions INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (store_id, sales_date, product_id, payment_method)
+);
+
+CREATE TABLE daily_category_sales (
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    sales_date DATE NOT NULL,
+    category_id INT NOT NULL,
+    payment_method VARCHAR(20) NOT NULL,
+    qty_sold INT NOT NULL DEFAULT 0,
+    revenue BIGINT NOT NULL DEFAULT 0,
+    total_transactions INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (store_id, sales_date, category_id, payment_method)
+);
+
+-- End-of-day reports per store. Rows are never updated; corrections are
+-- inserted as the next revision.
+CREATE TABLE report_snapshots (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id),
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    report_date DATE NOT NULL,
+    revision INT NOT NULL,
+    report JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'UTC'),
+    UNIQUE (store_id, report_date, revision)
+);
+
+CREATE FUNCTION forbid_report_snapshot_update() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'report snapshots are immutable, insert a new revision instead';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER report_snapshots_immutable BEFORE UPDATE ON report_snapshots
+    FOR EACH ROW EXECUTE FUNCTION forbid_report_snapshot_update();
+
+CREATE INDEX idx_stores_tenant ON stores (tenant_id);
+CREATE INDEX idx_categories_tenant ON categories (tenant_id);
+CREATE INDEX idx_products_tenant ON products (tenant_id);
+CREATE INDEX idx_products_search ON products USING gin (to_tsvector('simple', name || ' ' || COALESCE(sku, '')));
+CREATE INDEX idx_products_name_trgm ON products USING gin (name gin_trgm_ops);
+CREATE INDEX idx_products_sku_trgm ON products USING gin (sku gin_trgm_ops);
+CREATE INDEX idx_products_tenant_barcode ON products (tenant_id, barcode);
+CREATE INDEX idx_categories_name_search ON categories USING gin (to_tsvector('simple', name));
+CREATE INDEX idx_stock_receipts_store_product ON stock_receipts (store_id, product_id, received_at);
+CREATE INDEX idx_stock_transfers_tenant ON stock_transfers (tenant_id);
+CREATE INDEX idx_stock_transfer_lots_transfer ON stock_transfer_lots (transfer_id);
+CREATE INDEX idx_transactions_tenant_created ON transactions (tenant_id, created_at);
+CREATE INDEX idx_daily_sales_tenant_date ON daily_sales (tenant_id, sales_date);
+CREATE INDEX idx_daily_product_sales_tenant_date ON daily_product_sales (tenant_id, sales_date);
+CREATE INDEX idx_daily_category_sales_tenant_date ON daily_category_sales (tenant_id, sales_date);
+CREATE INDEX idx_report_snapshots_tenant_date ON report_snapshots (tenant_id, report_date);
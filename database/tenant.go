@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/spf13/viper"
+)
+
+// TenantDB runs queries on behalf of one tenant. When DB_ROW_LEVEL_SECURITY
+// is enabled it pins a single connection and sets app.tenant_id on it so the
+// Postgres row-level security policies apply; otherwise it uses the pool and
+// isolation relies on the tenant_id filters in the repository queries.
+type TenantDB struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+// ForTenant returns a handle for the tenant's queries. With row-level
+// security the pinned connection is taken under ctx.
+func ForTenant(ctx context.Context, tenantID int) (*TenantDB, error) {
+	db := GetDB()
+	if !viper.GetBool("DB_ROW_LEVEL_SECURITY") {
+		return &TenantDB{db: db}, nil
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, false)", strconv.Itoa(tenantID))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &TenantDB{db: db, conn: conn}, nil
+}
+
+func (t *TenantDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if t.conn == nil {
+		return t.db.QueryContext(ctx, query, args...)
+	}
+	return t.conn.QueryContext(ctx, query, args...)
+}
+
+func (t *TenantDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if t.conn == nil {
+		return t.db.QueryRowContext(ctx, query, args...)
+	}
+	return t.conn.QueryRowContext(ctx, query, args...)
+}
+
+func (t *TenantDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if t.conn == nil {
+		return t.db.ExecContext(ctx, query, args...)
+	}
+	return t.conn.ExecContext(ctx, query, args...)
+}
+
+func (t *TenantDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if t.conn == nil {
+		return t.db.BeginTx(ctx, opts)
+	}
+	return t.conn.BeginTx(ctx, opts)
+}
+
+// Close clears app.tenant_id and hands the pinned connection back to the pool.
+// It does not use the request's context: the reset must run even when the
+// request was cancelled.
+func (t *TenantDB) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	t.conn.ExecContext(context.Background(), "SELECT set_config('app.tenant_id', '', false)")
+	return t.conn.Close()
+}
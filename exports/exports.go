@@ -0,0 +1,109 @@
+package exports
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported export format, use json, csv or xlsx")
+
+// TimeLayout is how time values are written to exported cells.
+const TimeLayout = "2006-01-02 15:04:05"
+
+// Writer writes one table row at a time so exports can be streamed straight
+// from a database cursor. Close must be called to finish the file.
+type Writer interface {
+	WriteRow(values ...any) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format that writes to w. sheet names the
+// worksheet in XLSX files and is ignored for CSV.
+func NewWriter(w io.Writer, format, sheet string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w, sheet)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// FormatFromAccept maps an Accept header to an export format, or returns ""
+// when it names neither CSV nor XLSX.
+func FormatFromAccept(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return FormatCSV
+		case xlsxContentType:
+			return FormatXLSX
+		}
+	}
+	return ""
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return xlsxContentType
+	default:
+		return "application/json"
+	}
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvWriter) WriteRow(values ...any) error {
+	c.record = c.record[:0]
+	for _, v := range values {
+		c.record = append(c.record, formatValue(v))
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(TimeLayout)
+	default:
+		return ""
+	}
+}
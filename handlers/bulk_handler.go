@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"categories-api/auth"
+	"categories-api/models"
+	"categories-api/repositories"
+	"categories-api/utils"
+	"categories-api/validation"
+)
+
+// @Summary		Bulk update products
+// @Description	Apply one change (set price, adjust price by percentage, move to a category, set stock) to many products in one transaction. When any product fails nothing is saved and the per-item errors are returned with status 400.
+// @Tags			products
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			update	body		models.ProductBulkUpdate	true	"Product IDs and the change to apply"
+// @Success		200		{object}	models.BulkResult			"Updated"
+// @Failure		400		{object}	models.ErrorResponse		"One or more products failed"
+// @Failure		500		{object}	models.ErrorResponse		"Internal Server Error"
+// @Router			/products/bulk [patch]
+func BulkUpdateProductsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var update models.ProductBulkUpdate
+	if err := validation.Decode(w, r, &update); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	result, err := repositories.BulkUpdateProducts(r.Context(), auth.TenantID(r), update)
+	writeBulkResult(w, r, result, err)
+}
+
+// @Summary		Bulk delete products
+// @Description	Delete many products in one transaction. Products with transactions or stock transfers cannot be deleted; when any product fails nothing is deleted.
+// @Tags			products
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			ids	body		models.BulkDelete		true	"Product IDs"
+// @Success		200	{object}	models.BulkResult		"Deleted"
+// @Failure		400	{object}	models.ErrorResponse	"One or more products failed"
+// @Failure		500	{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/products/bulk [delete]
+func BulkDeleteProductsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.BulkDelete
+	if err := validation.Decode(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	result, err := repositories.BulkDeleteProducts(r.Context(), auth.TenantID(r), req.IDs)
+	writeBulkResult(w, r, result, err)
+}
+
+// @Summary		Bulk delete categories
+// @Description	Delete many categories in one transaction. Their products are handled as in a single delete: a category that still has products fails unless reassign_to moves them or cascade deletes them, and products with sales or transfer history cannot be cascaded. When any category fails nothing is deleted.
+// @Tags			categories
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			ids			body		models.BulkDelete		true	"Category IDs"
+// @Param			reassign_to	query		int						false	"Move the products to this category first"
+// @Param			cascade		query		bool					false	"Delete the products too"
+// @Success		200			{object}	models.BulkResult		"Deleted"
+// @Failure		400			{object}	models.ErrorResponse	"One or more categories failed"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/categories/bulk [delete]
+func BulkDeleteCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var opts repositories.CategoryDeleteOptions
+	var ok bool
+	if opts.ReassignTo, ok = queryID(w, r, "reassign_to"); !ok {
+		return
+	}
+	if opts.Cascade, ok = queryBool(w, r, "cascade"); !ok {
+		return
+	}
+
+	var req models.BulkDelete
+	if err := validation.Decode(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	result, err := repositories.BulkDeleteCategories(r.Context(), auth.TenantID(r), req.IDs, opts)
+	writeBulkResult(w, r, result, err)
+}
+
+// writeBulkResult answers 400 with a validation error when any item failed,
+// carrying the per-item results in details so the client can see which ones
+// to fix.
+func writeBulkResult(w http.ResponseWriter, r *http.Request, result *models.BulkResult, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if result.Failed > 0 {
+		utils.WriteError(w, http.StatusBadRequest, models.ErrCodeValidation,
+			fmt.Sprintf("%d of %d items failed", result.Failed, result.Total), result)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
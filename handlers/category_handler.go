@@ -4,80 +4,96 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"strings"
 
+	"categories-api/auth"
 	"categories-api/models"
 	"categories-api/repositories"
 	"categories-api/utils"
+	"categories-api/validation"
 )
 
 // @Summary		List all categories
-// @Description	Get all categories with optional pagination and search by name
+// @Description	Get all categories with optional pagination and search by name. Deleted categories are left out unless include_deleted=true.
 // @Tags			categories
 // @Accept			json
 // @Produce		json
-// @Param			page	query		int						false	"Page number"		default(1)
-// @Param			limit	query		int						false	"Items per page"	default(10)
-// @Param			name	query		string					false	"Search by name (case-insensitive)"
-// @Success		200		{object}	map[string]interface{}	"Success"
-// @Failure		400		{object}	map[string]string		"Bad Request"
-// @Failure		500		{object}	map[string]string		"Internal Server Error"
+// @Security		ApiKeyAuth
+// @Param			page			query		int						false	"Page number"		default(1)
+// @Param			limit			query		int						false	"Items per page"	default(10)
+// @Param			name			query		string					false	"Search by name (case-insensitive)"
+// @Param			include_deleted	query		bool					false	"Also list deleted categories, with deleted_at set"
+// @Success		200				{object}	map[string]interface{}	"Success"
+// @Failure		400				{object}	models.ErrorResponse	"Bad Request"
+// @Failure		500				{object}	models.ErrorResponse	"Internal Server Error"
 // @Router			/categories [get]
-func CategoriesHandler(w http.ResponseWriter, r *http.Request) {
+func ListCategoriesHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	tenantID := auth.TenantID(r)
+
+	name := r.URL.Query().Get("name")
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	includeDeleted, ok := queryBool(w, r, "include_deleted")
+	if !ok {
+		return
+	}
 
-	switch r.Method {
-	case http.MethodGet:
-		name := r.URL.Query().Get("name")
-		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-
-		if page == 0 {
-			page = 1
-		}
-		if limit == 0 {
-			limit = 10
-		}
-
-		var data []models.Category
-		if name != "" {
-			data = repositories.GetByName(name)
-		} else {
-			data = repositories.GetAll()
-		}
-		result := utils.Paginate(data, page, limit)
-
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"page":  page,
-			"limit": limit,
-			"data":  result,
-		})
-
-	case http.MethodPost:
-		//	@Summary		Create category
-		//	@Description	Create a new category
-		//	@Tags			categories
-		//	@Accept			json
-		//	@Produce		json
-		//	@Param			category	body		models.Category		true	"Category object"
-		//	@Success		201			{object}	models.Category		"Created"
-		//	@Failure		400			{object}	map[string]string	"Bad Request"
-		//	@Failure		500			{object}	map[string]string	"Internal Server Error"
-		//	@Router			/categories [post]
-		var category models.Category
-		json.NewDecoder(r.Body).Decode(&category)
-		created, err := repositories.Create(category)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			return
-		}
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(created)
-
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	var data []models.Category
+	var err error
+	if name != "" {
+		data, err = repositories.GetByName(r.Context(), tenantID, name)
+	} else {
+		data, err = repositories.GetAll(r.Context(), tenantID, includeDeleted)
 	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	result := utils.Paginate(data, page, limit)
+
+	writeJSONWithETag(w, r, map[string]interface{}{
+		"page":  page,
+		"limit": limit,
+		"data":  result,
+	})
+}
+
+// @Summary		Create category
+// @Description	Create a new category
+// @Tags			categories
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			category	body		models.Category			true	"Category object"
+// @Success		201			{object}	models.Category			"Created"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/categories [post]
+func CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var category models.Category
+	if err := validation.Decode(w, r, &category); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	created, err := repositories.Create(r.Context(), auth.TenantID(r), category)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(created.Version))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
 }
 
 // @Summary		Get category by ID
@@ -85,66 +101,235 @@ func CategoriesHandler(w http.ResponseWriter, r *http.Request) {
 // @Tags			categories
 // @Accept			json
 // @Produce		json
-// @Param			id	path		int					true	"Category ID"
-// @Success		200	{object}	models.Category		"Success"
-// @Failure		404	{object}	map[string]string	"Not Found"
+// @Security		ApiKeyAuth
+// @Param			id				path		int				true	"Category ID"
+// @Param			If-None-Match	header		string			false	"ETag of the copy the client already has"
+// @Success		200				{object}	models.Category	"Success"
+// @Header			200				{string}	ETag			"Version of the category, for If-None-Match and If-Match"
+// @Success		304				"Not Modified"
+// @Failure		400				{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404				{object}	models.ErrorResponse	"Not Found"
 // @Router			/categories/{id} [get]
-func CategoryDetailHandler(w http.ResponseWriter, r *http.Request) {
+func GetCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	category, err := repositories.GetByID(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if notModified(w, r, versionETag(category.Version)) {
+		return
+	}
+	json.NewEncoder(w).Encode(category)
+}
+
+// @Summary		Update category
+// @Description	Update an existing category
+// @Tags			categories
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id			path		int						true	"Category ID"
+// @Param			If-Match	header		string					false	"ETag the change is based on"
+// @Param			category	body		models.Category			true	"Category object"
+// @Success		200			{object}	models.Category			"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request - Invalid ID or body"
+// @Failure		404			{object}	models.ErrorResponse	"Not Found"
+// @Failure		412			{object}	models.ErrorResponse	"Precondition Failed - Category was modified"
+// @Failure		428			{object}	models.ErrorResponse	"Precondition Required - If-Match missing"
+// @Router			/categories/{id} [put]
+func UpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	pre, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var category models.Category
+	if err := validation.Decode(w, r, &category); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	updated, err := repositories.Update(r.Context(), auth.TenantID(r), id, category, pre)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(updated.Version))
+	json.NewEncoder(w).Encode(updated)
+}
+
+// @Summary		Patch category
+// @Description	Change some fields of a category with a JSON merge patch (RFC 7396, Content-Type application/merge-patch+json or application/json). Fields left out keep their value and null clears a field. The patched category is validated as a whole; only name and description can be changed.
+// @Tags			categories
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id			path		int						true	"Category ID"
+// @Param			If-Match	header		string					false	"ETag the change is based on"
+// @Param			patch		body		models.Category			true	"Fields to change"
+// @Success		200			{object}	models.Category			"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request - Invalid ID, patch or patched category"
+// @Failure		404			{object}	models.ErrorResponse	"Not Found"
+// @Failure		412			{object}	models.ErrorResponse	"Precondition Failed - Category was modified"
+// @Failure		428			{object}	models.ErrorResponse	"Precondition Required - If-Match missing"
+// @Router			/categories/{id} [patch]
+func PatchCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	idStr := strings.TrimPrefix(r.URL.Path, "/categories/")
-	id, _ := strconv.Atoi(idStr)
-
-	switch r.Method {
-	case http.MethodGet:
-		category, err := repositories.GetByID(id)
-		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			return
-		}
-		json.NewEncoder(w).Encode(category)
-
-	case http.MethodPut:
-		//	@Summary		Update category
-		//	@Description	Update an existing category
-		//	@Tags			categories
-		//	@Accept			json
-		//	@Produce		json
-		//	@Param			id			path		int					true	"Category ID"
-		//	@Param			category	body		models.Category		true	"Category object"
-		//	@Success		200			{object}	models.Category		"Success"
-		//	@Failure		404			{object}	map[string]string	"Not Found"
-		//	@Router			/categories/{id} [put]
-		var category models.Category
-		json.NewDecoder(r.Body).Decode(&category)
-
-		updated, err := repositories.Update(id, category)
-		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			return
-		}
-		json.NewEncoder(w).Encode(updated)
-
-	case http.MethodDelete:
-		//	@Summary		Delete category
-		//	@Description	Delete a category by ID
-		//	@Tags			categories
-		//	@Accept			json
-		//	@Produce		json
-		//	@Param			id	path	int	true	"Category ID"
-		//	@Success		204	"No Content"
-		//	@Failure		404	{object}	map[string]string	"Not Found"
-		//	@Router			/categories/{id} [delete]
-		err := repositories.Delete(id)
-		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			return
-		}
-		w.WriteHeader(http.StatusNoContent)
-
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+
+	pre, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	patch, err := validation.DecodePatch(w, r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	patched, err := repositories.PatchCategory(r.Context(), auth.TenantID(r), id, patch, pre)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(patched.Version))
+	json.NewEncoder(w).Encode(patched)
+}
+
+// @Summary		Delete category
+// @Description	Delete a category by ID; it can be restored until the purge command removes it. A category that still has products is only deleted when reassign_to moves them to another category or cascade deletes them; products with sales or transfer history cannot be cascaded.
+// @Tags			categories
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id			path	int		true	"Category ID"
+// @Param			reassign_to	query	int		false	"Move the products to this category first"
+// @Param			cascade		query	bool	false	"Delete the products too"
+// @Param			If-Match	header	string	false	"ETag the delete is based on"
+// @Success		204			"No Content"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request - Invalid ID or reassign_to"
+// @Failure		404			{object}	models.ErrorResponse	"Not Found"
+// @Failure		409			{object}	models.ErrorResponse	"Conflict - Category still has products (details count them)"
+// @Failure		412			{object}	models.ErrorResponse	"Precondition Failed - Category was modified"
+// @Failure		428			{object}	models.ErrorResponse	"Precondition Required - If-Match missing"
+// @Router			/categories/{id} [delete]
+func DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var opts repositories.CategoryDeleteOptions
+	if opts.ReassignTo, ok = queryID(w, r, "reassign_to"); !ok {
+		return
 	}
+	if opts.Cascade, ok = queryBool(w, r, "cascade"); !ok {
+		return
+	}
+	pre, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	err := repositories.Delete(r.Context(), auth.TenantID(r), id, opts, pre)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary		Restore category
+// @Description	Undo a category delete, together with the products its cascade deleted
+// @Tags			categories
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Category ID"
+// @Success		200	{object}	models.Category			"Success"
+// @Failure		400	{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Failure		409	{object}	models.ErrorResponse	"Conflict - Category is not deleted"
+// @Router			/categories/{id}/restore [post]
+func RestoreCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	category, err := repositories.Restore(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(category.Version))
+	json.NewEncoder(w).Encode(category)
+}
+
+// @Summary		List products of a category
+// @Description	Get the products of one category with optional pagination
+// @Tags			categories
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id		path		int						true	"Category ID"
+// @Param			page	query		int						false	"Page number"		default(1)
+// @Param			limit	query		int						false	"Items per page"	default(10)
+// @Success		200		{object}	map[string]interface{}	"Success"
+// @Failure		400		{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404		{object}	models.ErrorResponse	"Not Found"
+// @Failure		500		{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/categories/{id}/products [get]
+func CategoryProductsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	tenantID := auth.TenantID(r)
+
+	if _, err := repositories.GetByID(r.Context(), tenantID, id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	data, err := repositories.GetProductsByCategoryID(r.Context(), tenantID, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	result := utils.Paginate(data, page, limit)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"page":  page,
+		"limit": limit,
+		"data":  result,
+	})
 }
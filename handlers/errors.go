@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"categories-api/middleware"
+	"categories-api/models"
+	"categories-api/repositories"
+	"categories-api/utils"
+	"categories-api/validation"
+
+	"github.com/lib/pq"
+)
+
+// writeError answers with the error envelope, taking the status and code
+// from the error type. Database and unexpected errors are logged with the
+// request ID and answered without their raw message. Once the request's
+// query timeout has passed any error is answered as a timeout, whatever the
+// driver made of the cancelled query.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		invalid  *repositories.ValidationError
+		fields   validation.Errors
+		stock    *repositories.InsufficientStockError
+		notFound *repositories.NotFoundError
+		conflict *repositories.ConflictError
+		modified *repositories.PreconditionFailedError
+		pqErr    *pq.Error
+	)
+
+	switch {
+	case errors.Is(r.Context().Err(), context.DeadlineExceeded):
+		utils.WriteError(w, http.StatusServiceUnavailable, models.ErrCodeTimeout, "the request took too long; narrow it down (e.g. a shorter date range) or try again", nil)
+
+	case errors.Is(r.Context().Err(), context.Canceled):
+		// The client went away; nobody reads the answer.
+
+	case errors.As(err, &fields):
+		utils.WriteError(w, http.StatusBadRequest, models.ErrCodeValidation, "invalid request body", map[string]validation.Errors{
+			"fields": fields,
+		})
+
+	case errors.Is(err, validation.ErrBodyTooLarge):
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, models.ErrCodeRequestTooLarge, err.Error(), nil)
+
+	case errors.As(err, &invalid):
+		var details any
+		if invalid.ProductID > 0 {
+			details = map[string]int{"product_id": invalid.ProductID}
+		}
+		utils.WriteError(w, http.StatusBadRequest, models.ErrCodeValidation, invalid.Message, details)
+
+	case errors.As(err, &stock):
+		utils.WriteError(w, http.StatusConflict, models.ErrCodeInsufficientStock, "insufficient stock for product", map[string]int{
+			"product_id": stock.ProductID,
+			"requested":  stock.Requested,
+			"available":  stock.Available,
+		})
+
+	case errors.As(err, &notFound):
+		utils.WriteError(w, http.StatusNotFound, models.ErrCodeNotFound, notFound.Error(), nil)
+
+	case errors.Is(err, sql.ErrNoRows):
+		utils.WriteError(w, http.StatusNotFound, models.ErrCodeNotFound, "not found", nil)
+
+	case errors.As(err, &conflict):
+		var details any
+		if len(conflict.Details) > 0 {
+			details = conflict.Details
+		}
+		utils.WriteError(w, http.StatusConflict, models.ErrCodeConflict, conflict.Message, details)
+
+	case errors.As(err, &modified):
+		utils.WriteError(w, http.StatusPreconditionFailed, models.ErrCodePreconditionFailed, modified.Error(), map[string]string{
+			"etag": versionETag(modified.Version),
+		})
+
+	case errors.As(err, &pqErr):
+		writeDatabaseError(w, r, pqErr)
+
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+// writeDatabaseError maps the constraint violations a client can cause to
+// 4xx responses. The constraint and column names are schema details and are
+// not echoed back.
+func writeDatabaseError(w http.ResponseWriter, r *http.Request, err *pq.Error) {
+	switch err.Code.Name() {
+	case "foreign_key_violation":
+		utils.WriteError(w, http.StatusConflict, models.ErrCodeConflict, "the record is still referenced by other records, or references one that does not exist", nil)
+	case "unique_violation":
+		utils.WriteError(w, http.StatusConflict, models.ErrCodeConflict, "a record with the same value already exists", nil)
+	case "not_null_violation", "check_violation", "string_data_right_truncation", "numeric_value_out_of_range", "invalid_text_representation":
+		utils.WriteError(w, http.StatusBadRequest, models.ErrCodeValidation, "a value is missing, too long or out of range", nil)
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "request failed",
+		slog.String("request_id", middleware.RequestIDFrom(r.Context())),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("error", err.Error()),
+	)
+	utils.WriteError(w, http.StatusInternalServerError, models.ErrCodeInternal, "internal server error", nil)
+}
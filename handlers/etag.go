@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"categories-api/models"
+	"categories-api/repositories"
+	"categories-api/utils"
+
+	"github.com/spf13/viper"
+)
+
+// versionETag is the strong ETag of a product or category at a version.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// notModified sets the ETag header and answers 304 when the request's
+// If-None-Match already names it, so polling clients skip the body.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if !noneMatchHit(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// writeJSONWithETag encodes v with a weak ETag derived from the body, for
+// lists that have no version of their own. A matching If-None-Match gets a
+// 304 instead of the body.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	if notModified(w, r, `W/"`+hex.EncodeToString(sum[:16])+`"`) {
+		return
+	}
+	w.Write(append(body, '\n'))
+}
+
+// noneMatchHit compares If-None-Match weakly, as RFC 9110 asks: W/ prefixes
+// are ignored and "*" matches anything.
+func noneMatchHit(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch turns the If-Match header of an update or delete into a
+// precondition on the record's version. Without the header any version is
+// accepted, unless REQUIRE_IF_MATCH is set: then the request is answered
+// with 428 and ok is false. Weak ETags never match, as If-Match compares
+// strongly.
+func ifMatch(w http.ResponseWriter, r *http.Request) (pre repositories.Precondition, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if viper.GetBool("REQUIRE_IF_MATCH") {
+			utils.WriteError(w, http.StatusPreconditionRequired, models.ErrCodePreconditionRequired, "If-Match header is required; send the ETag of the record you read", nil)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	versions := map[int]bool{}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return nil, true
+		}
+		unquoted, err := strconv.Unquote(candidate)
+		if err != nil {
+			continue
+		}
+		if version, err := strconv.Atoi(unquoted); err == nil {
+			versions[version] = true
+		}
+	}
+	return func(version int) bool { return versions[version] }, true
+}
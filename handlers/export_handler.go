@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"categories-api/exports"
+)
+
+// exportFormat picks csv or xlsx from the format query parameter, falling
+// back to the Accept header. It returns "" when JSON was asked for.
+func exportFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+		return exports.FormatFromAccept(r.Header.Get("Accept")), nil
+	case "json":
+		return "", nil
+	case exports.FormatCSV, exports.FormatXLSX:
+		return format, nil
+	default:
+		return "", exports.ErrUnsupportedFormat
+	}
+}
+
+// IsExport reports whether the request asks for a CSV or XLSX export
+// rather than JSON.
+func IsExport(r *http.Request) bool {
+	format, err := exportFormat(r)
+	return err == nil && format != ""
+}
+
+// exportStream delays the response headers until the first row is written,
+// so errors raised before any data (bad dates, unknown store) can still be
+// answered with a JSON error.
+type exportStream struct {
+	w      http.ResponseWriter
+	format string
+	name   string
+	header []any
+	out    exports.Writer
+}
+
+func (e *exportStream) start() error {
+	e.w.Header().Set("Content-Type", exports.ContentType(e.format))
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", e.name, e.format))
+
+	out, err := exports.NewWriter(e.w, e.format, e.name)
+	if err != nil {
+		return err
+	}
+	e.out = out
+	return e.out.WriteRow(e.header...)
+}
+
+func (e *exportStream) WriteRow(values ...any) error {
+	if e.out == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	return e.out.WriteRow(values...)
+}
+
+func (e *exportStream) Close() error {
+	if e.out == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	return e.out.Close()
+}
+
+// writeExport streams a table as a CSV or XLSX attachment named name. Once
+// rows have been sent the status can no longer change, so a later error is
+// logged and the connection aborted: the client sees a failed download
+// rather than a complete-looking but truncated file.
+func writeExport(w http.ResponseWriter, r *http.Request, format, name string, header []any, stream func(exports.Writer) error) {
+	// An export of a long range can take longer than HTTP_WRITE_TIMEOUT;
+	// the stream itself is not cut off.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	out := &exportStream{w: w, format: format, name: name, header: header}
+
+	err := stream(out)
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		if out.out == nil {
+			writeError(w, r, err)
+			return
+		}
+		log.Printf("export %s: %v", name, err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"categories-api/auth"
+	"categories-api/models"
+	"categories-api/repositories"
+	"categories-api/utils"
+)
+
+// pathID reads a numeric path parameter such as {id}. A missing, malformed
+// or non-positive value is answered with 400 and ok is false.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (id int, ok bool) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil || id <= 0 {
+		utils.WriteError(w, http.StatusBadRequest, models.ErrCodeValidation, "invalid "+name+": "+strconv.Quote(r.PathValue(name)), map[string]string{"parameter": name})
+		return 0, false
+	}
+	return id, true
+}
+
+// queryID reads an optional numeric query parameter such as ?reassign_to=.
+// It returns 0 when the parameter is absent; a malformed or non-positive
+// value is answered with 400 and ok is false.
+func queryID(w http.ResponseWriter, r *http.Request, name string) (id int, ok bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		utils.WriteError(w, http.StatusBadRequest, models.ErrCodeValidation, "invalid "+name+": "+strconv.Quote(value), map[string]string{"parameter": name})
+		return 0, false
+	}
+	return id, true
+}
+
+// queryBool reads an optional boolean query parameter such as ?cascade=true.
+// It returns false when the parameter is absent; a malformed value is
+// answered with 400 and ok is false.
+func queryBool(w http.ResponseWriter, r *http.Request, name string) (value bool, ok bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return false, true
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, models.ErrCodeValidation, "invalid "+name+": "+strconv.Quote(raw), map[string]string{"parameter": name})
+		return false, false
+	}
+	return value, true
+}
+
+// queryStoreID reads the optional store_id filter of reports and exports.
+// It returns 0 (all stores) when the parameter is absent; a malformed value
+// is answered with 400 and a store the tenant does not have with 404, and ok
+// is false.
+func queryStoreID(w http.ResponseWriter, r *http.Request) (storeID int, ok bool) {
+	storeID, ok = queryID(w, r, "store_id")
+	if !ok || storeID == 0 {
+		return storeID, ok
+	}
+	if _, err := repositories.GetStoreByID(r.Context(), auth.TenantID(r), storeID); err != nil {
+		writeError(w, r, err)
+		return 0, false
+	}
+	return storeID, true
+}
@@ -4,85 +4,111 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"strings"
 
+	"categories-api/auth"
 	"categories-api/models"
 	"categories-api/repositories"
 	"categories-api/utils"
+	"categories-api/validation"
 )
 
 // @Summary		List all products
-// @Description	Get all products with optional pagination, search by name, and filter by category
+// @Description	Get all products with optional pagination, relevance-ranked search, search by name, and filter by category. Archived and deleted products are left out unless archived=true or include_deleted=true.
 // @Tags			products
 // @Accept			json
 // @Produce		json
-// @Param			page		query		int						false	"Page number"		default(1)
-// @Param			limit		query		int						false	"Items per page"	default(10)
-// @Param			name		query		string					false	"Search by name (case-insensitive)"
-// @Param			category_id	query		int						false	"Filter by category ID"
-// @Success		200			{object}	map[string]interface{}	"Success"
-// @Failure		400			{object}	map[string]string		"Bad Request"
-// @Failure		500			{object}	map[string]string		"Internal Server Error"
+// @Security		ApiKeyAuth
+// @Param			page			query		int						false	"Page number"		default(1)
+// @Param			limit			query		int						false	"Items per page"	default(10)
+// @Param			q				query		string					false	"Search name, SKU and category, best match first (prefix and typo tolerant)"
+// @Param			name			query		string					false	"Search by name (case-insensitive)"
+// @Param			category_id		query		int						false	"Filter by category ID"
+// @Param			archived		query		bool					false	"List only archived products"
+// @Param			include_deleted	query		bool					false	"Also list deleted products, with deleted_at set"
+// @Success		200				{object}	map[string]interface{}	"Success"
+// @Failure		400				{object}	models.ErrorResponse	"Bad Request"
+// @Failure		500				{object}	models.ErrorResponse	"Internal Server Error"
 // @Router			/products [get]
-func ProductsHandler(w http.ResponseWriter, r *http.Request) {
+func ListProductsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	tenantID := auth.TenantID(r)
 
-	switch r.Method {
-	case http.MethodGet:
-		name := r.URL.Query().Get("name")
-		categoryID, _ := strconv.Atoi(r.URL.Query().Get("category_id"))
-		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-
-		if page == 0 {
-			page = 1
-		}
-		if limit == 0 {
-			limit = 10
-		}
-
-		var data []models.Product
-		if name != "" {
-			data = repositories.GetProductsByName(name)
-		} else if categoryID > 0 {
-			data = repositories.GetProductsByCategoryID(categoryID)
-		} else {
-			data = repositories.GetAllProducts()
-		}
-
-		result := utils.Paginate(data, page, limit)
-
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"page":  page,
-			"limit": limit,
-			"data":  result,
-		})
-
-	case http.MethodPost:
-		//	@Summary		Create product
-		//	@Description	Create a new product
-		//	@Tags			products
-		//	@Accept			json
-		//	@Produce		json
-		//	@Param			product	body		models.Product		true	"Product object"
-		//	@Success		201		{object}	models.Product		"Created"
-		//	@Failure		400		{object}	map[string]string	"Bad Request"
-		//	@Failure		500		{object}	map[string]string	"Internal Server Error"
-		//	@Router			/products [post]
-		var product models.Product
-		json.NewDecoder(r.Body).Decode(&product)
-		created, err := repositories.CreateProduct(product)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			return
-		}
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(created)
-
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	name := r.URL.Query().Get("name")
+	categoryID, _ := strconv.Atoi(r.URL.Query().Get("category_id"))
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	archived, ok := queryBool(w, r, "archived")
+	if !ok {
+		return
+	}
+	includeDeleted, ok := queryBool(w, r, "include_deleted")
+	if !ok {
+		return
+	}
+
+	var data []models.Product
+	var err error
+	if archived {
+		data, err = repositories.GetArchivedProducts(r.Context(), tenantID)
+	} else if q := r.URL.Query().Get("q"); q != "" {
+		data, err = repositories.SearchProducts(r.Context(), tenantID, q)
+	} else if name != "" {
+		data, err = repositories.GetProductsByName(r.Context(), tenantID, name)
+	} else if categoryID > 0 {
+		data, err = repositories.GetProductsByCategoryID(r.Context(), tenantID, categoryID)
+	} else {
+		data, err = repositories.GetAllProducts(r.Context(), tenantID, includeDeleted)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
+
+	result := utils.Paginate(data, page, limit)
+
+	writeJSONWithETag(w, r, map[string]interface{}{
+		"page":  page,
+		"limit": limit,
+		"data":  result,
+	})
+}
+
+// @Summary		Create product
+// @Description	Create a new product
+// @Tags			products
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			product	body		models.Product			true	"Product object"
+// @Success		201		{object}	models.Product			"Created"
+// @Failure		400		{object}	models.ErrorResponse	"Bad Request"
+// @Failure		409		{object}	models.ErrorResponse	"Conflict - SKU already used"
+// @Failure		500		{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/products [post]
+func CreateProductHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var product models.Product
+	if err := validation.Decode(w, r, &product); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	created, err := repositories.CreateProduct(r.Context(), auth.TenantID(r), product)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(created.Version))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
 }
 
 // @Summary		Get product by ID
@@ -90,66 +116,251 @@ func ProductsHandler(w http.ResponseWriter, r *http.Request) {
 // @Tags			products
 // @Accept			json
 // @Produce		json
-// @Param			id	path		int					true	"Product ID"
-// @Success		200	{object}	models.Product		"Success"
-// @Failure		404	{object}	map[string]string	"Not Found"
+// @Security		ApiKeyAuth
+// @Param			id				path		int				true	"Product ID"
+// @Param			If-None-Match	header		string			false	"ETag of the copy the client already has"
+// @Success		200				{object}	models.Product	"Success"
+// @Header			200				{string}	ETag			"Version of the product, for If-None-Match and If-Match"
+// @Success		304				"Not Modified"
+// @Failure		400				{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404				{object}	models.ErrorResponse	"Not Found"
 // @Router			/products/{id} [get]
-func ProductDetailHandler(w http.ResponseWriter, r *http.Request) {
+func GetProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	product, err := repositories.GetProductByID(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if notModified(w, r, versionETag(product.Version)) {
+		return
+	}
+	json.NewEncoder(w).Encode(product)
+}
+
+// @Summary		Update product
+// @Description	Update an existing product
+// @Tags			products
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id			path		int						true	"Product ID"
+// @Param			If-Match	header		string					false	"ETag the change is based on"
+// @Param			product		body		models.Product			true	"Product object"
+// @Success		200			{object}	models.Product			"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse	"Not Found"
+// @Failure		409			{object}	models.ErrorResponse	"Conflict - SKU already used"
+// @Failure		412			{object}	models.ErrorResponse	"Precondition Failed - Product was modified"
+// @Failure		428			{object}	models.ErrorResponse	"Precondition Required - If-Match missing"
+// @Router			/products/{id} [put]
+func UpdateProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	pre, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var product models.Product
+	if err := validation.Decode(w, r, &product); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	updated, err := repositories.UpdateProduct(r.Context(), auth.TenantID(r), id, product, pre)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(updated.Version))
+	json.NewEncoder(w).Encode(updated)
+}
+
+// @Summary		Patch product
+// @Description	Change some fields of a product with a JSON merge patch (RFC 7396, Content-Type application/merge-patch+json or application/json). Fields left out keep their value and null clears a field. The patched product is validated as a whole; only sku, name, price, cost, stock, categories_id and barcode can be changed.
+// @Tags			products
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id			path		int						true	"Product ID"
+// @Param			If-Match	header		string					false	"ETag the change is based on"
+// @Param			patch		body		models.Product			true	"Fields to change"
+// @Success		200			{object}	models.Product			"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request - Invalid ID, patch or patched product"
+// @Failure		404			{object}	models.ErrorResponse	"Not Found"
+// @Failure		409			{object}	models.ErrorResponse	"Conflict - SKU already used"
+// @Failure		412			{object}	models.ErrorResponse	"Precondition Failed - Product was modified"
+// @Failure		428			{object}	models.ErrorResponse	"Precondition Required - If-Match missing"
+// @Router			/products/{id} [patch]
+func PatchProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	pre, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	patch, err := validation.DecodePatch(w, r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	patched, err := repositories.PatchProduct(r.Context(), auth.TenantID(r), id, patch, pre)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(patched.Version))
+	json.NewEncoder(w).Encode(patched)
+}
+
+// @Summary		Delete product
+// @Description	Delete a product by ID. It can be restored until the purge command removes it. Products with sales or transfer history cannot be deleted; archive them instead.
+// @Tags			products
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id			path	int		true	"Product ID"
+// @Param			If-Match	header	string	false	"ETag the delete is based on"
+// @Success		204			"No Content"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404			{object}	models.ErrorResponse	"Not Found"
+// @Failure		409			{object}	models.ErrorResponse	"Conflict - Product has history (details count transactions and transfers)"
+// @Failure		412			{object}	models.ErrorResponse	"Precondition Failed - Product was modified"
+// @Failure		428			{object}	models.ErrorResponse	"Precondition Required - If-Match missing"
+// @Router			/products/{id} [delete]
+func DeleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	pre, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	err := repositories.DeleteProduct(r.Context(), auth.TenantID(r), id, pre)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary		Restore product
+// @Description	Undo a product delete. A product whose category is deleted too is refused; restore the category first, which brings back the products its cascade deleted.
+// @Tags			products
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Product ID"
+// @Success		200	{object}	models.Product			"Success"
+// @Failure		400	{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Failure		409	{object}	models.ErrorResponse	"Conflict - Product is not deleted, or its category is deleted"
+// @Router			/products/{id}/restore [post]
+func RestoreProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	product, err := repositories.RestoreProduct(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(product.Version))
+	json.NewEncoder(w).Encode(product)
+}
+
+// @Summary		Archive product
+// @Description	Hide a product from the catalog, suggestions and checkout while keeping its stock and sales history
+// @Tags			products
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Product ID"
+// @Success		200	{object}	models.Product			"Success"
+// @Failure		400	{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Router			/products/{id}/archive [post]
+func ArchiveProductHandler(w http.ResponseWriter, r *http.Request) {
+	setProductArchived(w, r, true)
+}
+
+// @Summary		Unarchive product
+// @Description	Bring an archived product back into the catalog
+// @Tags			products
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Product ID"
+// @Success		200	{object}	models.Product			"Success"
+// @Failure		400	{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Router			/products/{id}/unarchive [post]
+func UnarchiveProductHandler(w http.ResponseWriter, r *http.Request) {
+	setProductArchived(w, r, false)
+}
+
+func setProductArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	idStr := strings.TrimPrefix(r.URL.Path, "/products/")
-	id, _ := strconv.Atoi(idStr)
-
-	switch r.Method {
-	case http.MethodGet:
-		product, err := repositories.GetProductByID(id)
-		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			return
-		}
-		json.NewEncoder(w).Encode(product)
-
-	case http.MethodPut:
-		//	@Summary		Update product
-		//	@Description	Update an existing product
-		//	@Tags			products
-		//	@Accept			json
-		//	@Produce		json
-		//	@Param			id		path		int					true	"Product ID"
-		//	@Param			product	body		models.Product		true	"Product object"
-		//	@Success		200		{object}	models.Product		"Success"
-		//	@Failure		404		{object}	map[string]string	"Not Found"
-		//	@Router			/products/{id} [put]
-		var product models.Product
-		json.NewDecoder(r.Body).Decode(&product)
-
-		updated, err := repositories.UpdateProduct(id, product)
-		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			return
-		}
-		json.NewEncoder(w).Encode(updated)
-
-	case http.MethodDelete:
-		//	@Summary		Delete product
-		//	@Description	Delete a product by ID
-		//	@Tags			products
-		//	@Accept			json
-		//	@Produce		json
-		//	@Param			id	path	int	true	"Product ID"
-		//	@Success		204	"No Content"
-		//	@Failure		404	{object}	map[string]string	"Not Found"
-		//	@Router			/products/{id} [delete]
-		err := repositories.DeleteProduct(id)
-		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			return
-		}
-		w.WriteHeader(http.StatusNoContent)
-
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+
+	product, err := repositories.ArchiveProduct(r.Context(), auth.TenantID(r), id, archived)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(product.Version))
+	json.NewEncoder(w).Encode(product)
+}
+
+// @Summary		Suggest products
+// @Description	Type-ahead for POS clients: products whose name, SKU or barcode words start with every word of q, exact SKU or barcode first. Served from an in-memory index.
+// @Tags			products
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			q		query		string						true	"What the cashier typed so far"
+// @Param			limit	query		int							false	"Maximum suggestions (max 50)"	default(10)
+// @Success		200		{array}		models.ProductSuggestion	"Success"
+// @Failure		500		{object}	models.ErrorResponse		"Internal Server Error"
+// @Router			/products/suggest [get]
+func ProductSuggestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > repositories.MaxSuggestions {
+		limit = repositories.MaxSuggestions
+	}
+
+	suggestions, err := repositories.SuggestProducts(r.Context(), auth.TenantID(r), r.URL.Query().Get("q"), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
+	json.NewEncoder(w).Encode(suggestions)
 }
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"categories-api/auth"
+	"categories-api/models"
+	"categories-api/repositories"
+	"categories-api/utils"
+)
+
+// maxImportSize caps the size of an uploaded import file.
+const maxImportSize = 10 << 20
+
+// importColumns are the CSV columns a product import understands. The first
+// four are required.
+var importColumns = []string{"sku", "name", "price", "category", "cost", "stock"}
+
+// @Summary		Import products
+// @Description	Create or update products by SKU from a CSV file (header: sku,name,price,category,cost,stock) or JSON lines. Categories are matched by name. Every row is validated; when any row fails nothing is saved and the per-row errors are returned with status 400.
+// @Tags			products
+// @Accept			text/csv
+// @Accept			application/x-ndjson
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			format				query		string						false	"Input format, detected from Content-Type or the content when omitted"	Enums(csv, jsonl)
+// @Param			dry_run				query		bool						false	"Validate and report without saving"
+// @Param			create_categories	query		bool						false	"Create categories that do not exist yet"
+// @Param			file				body		string						true	"CSV or JSON lines"
+// @Success		200					{object}	models.ProductImportResult	"Imported (or validated on a dry run)"
+// @Failure		400					{object}	models.ErrorResponse		"One or more rows are invalid"
+// @Failure		500					{object}	models.ErrorResponse		"Internal Server Error"
+// @Router			/products/import [post]
+func ProductImportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dryRun, ok := queryBool(w, r, "dry_run")
+	if !ok {
+		return
+	}
+	createCategories, ok := queryBool(w, r, "create_categories")
+	if !ok {
+		return
+	}
+
+	rows, err := readImportRows(w, r)
+	if err != nil {
+		writeError(w, r, &repositories.ValidationError{Message: err.Error()})
+		return
+	}
+
+	result, err := repositories.ImportProducts(r.Context(), auth.TenantID(r), rows, repositories.ImportOptions{
+		DryRun:           dryRun,
+		CreateCategories: createCategories,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if result.Failed > 0 {
+		utils.WriteError(w, http.StatusBadRequest, models.ErrCodeValidation,
+			fmt.Sprintf("%d of %d rows are invalid", result.Failed, result.Total), result)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// readImportRows parses the request body as CSV or JSON lines, chosen by the
+// format query parameter, then the Content-Type, then the first character of
+// the body. Problems with a single row are kept on the row so they show up in
+// the import report; only an unreadable file is returned as an error.
+func readImportRows(w http.ResponseWriter, r *http.Request) ([]models.ProductImportRow, error) {
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxImportSize))
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = "csv"
+		case "application/x-ndjson", "application/jsonl", "application/json":
+			format = "jsonl"
+		default:
+			format = "csv"
+			if first, err := firstByte(body); err == nil && first == '{' {
+				format = "jsonl"
+			}
+		}
+	}
+
+	switch format {
+	case "csv":
+		return readImportCSV(body)
+	case "jsonl":
+		return readImportJSONLines(body)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q, use csv or jsonl", format)
+	}
+}
+
+// firstByte peeks at the first non-blank byte of the body without consuming it.
+func firstByte(body *bufio.Reader) (byte, error) {
+	for n := 1; ; n++ {
+		peek, err := body.Peek(n)
+		if len(peek) < n {
+			return 0, err
+		}
+		if c := peek[n-1]; c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return c, nil
+		}
+	}
+}
+
+func readImportCSV(body io.Reader) ([]models.ProductImportRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("import file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, name := range importColumns[:4] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q, expected %s", name, strings.Join(importColumns, ","))
+		}
+	}
+
+	var rows []models.ProductImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := models.ProductImportRow{
+			Row:      line,
+			SKU:      field("sku"),
+			Name:     field("name"),
+			Category: field("category"),
+		}
+		row.Price = importInt(&row, "price", field("price"))
+		row.Cost = importInt(&row, "cost", field("cost"))
+		if stock := field("stock"); stock != "" {
+			n := importInt(&row, "stock", stock)
+			row.Stock = &n
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// importInt parses a whole number, recording an error on the row when it is
+// not one. An empty value is zero.
+func importInt(row *models.ProductImportRow, name, value string) int {
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		row.Errors = append(row.Errors, name+" must be a whole number")
+	}
+	return n
+}
+
+func readImportJSONLines(body io.Reader) ([]models.ProductImportRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxImportSize)
+
+	var rows []models.ProductImportRow
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		row := models.ProductImportRow{Row: line}
+		if err := json.Unmarshal(text, &row); err != nil {
+			row.Errors = append(row.Errors, "invalid JSON: "+err.Error())
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("import file is empty")
+	}
+	return rows, nil
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"categories-api/auth"
+	"categories-api/receipts"
+	"categories-api/repositories"
+)
+
+// @Summary		Get transaction receipt
+// @Description	Render a printable receipt for a transaction as plain text, HTML, PDF or ESC/POS printer commands
+// @Tags			transactions
+// @Produce		plain
+// @Produce		html
+// @Produce		application/pdf
+// @Produce		application/octet-stream
+// @Security		ApiKeyAuth
+// @Param			id		path		int						true	"Transaction ID"
+// @Param			format	query		string					false	"Receipt format"	Enums(text, html, pdf, escpos)	default(text)
+// @Success		200		{string}	string					"Receipt"
+// @Failure		400		{object}	models.ErrorResponse	"Bad Request - Invalid ID or unsupported format"
+// @Failure		404		{object}	models.ErrorResponse	"Not Found"
+// @Failure		500		{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/transactions/{id}/receipt [get]
+func TransactionReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = receipts.FormatText
+	}
+
+	receipt, err := repositories.GetReceiptByTransactionID(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	receipts.ApplyStoreInfo(receipt)
+
+	var buf bytes.Buffer
+	err = receipts.Render(&buf, format, receipt)
+	if err == receipts.ErrUnsupportedFormat {
+		err = &repositories.ValidationError{Message: err.Error()}
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", receipts.ContentType(format))
+	if format == receipts.FormatPDF || format == receipts.FormatESCPOS {
+		ext := "pdf"
+		if format == receipts.FormatESCPOS {
+			ext = "bin"
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"receipt-%d.%s\"", id, ext))
+	}
+	w.Write(buf.Bytes())
+}
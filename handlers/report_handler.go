@@ -3,8 +3,13 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
+	"categories-api/auth"
+	"categories-api/exports"
+	"categories-api/models"
 	"categories-api/repositories"
+	"categories-api/utils"
 )
 
 // @Summary		Get today's report
@@ -12,21 +17,27 @@ import (
 // @Tags			reports
 // @Accept			json
 // @Produce		json
-// @Success		200	{object}	models.DailyReport	"Success"
-// @Failure		500	{object}	map[string]string	"Internal Server Error"
+// @Security		ApiKeyAuth
+// @Param			store_id	query		int						false	"Filter by store ID (omit for consolidated report)"
+// @Param			tz			query		string					false	"IANA timezone for day boundaries, e.g. Asia/Jakarta (default: store timezone)"
+// @Param			top			query		int						false	"Rank the top N products instead of listing all tied for first place"
+// @Param			sort_by		query		string					false	"Ranking key when top is set"	Enums(quantity, revenue)	default(quantity)
+// @Success		200			{object}	models.DailyReport		"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse	"Store not found"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
 // @Router			/api/report/hari-ini [get]
 func TodayReportHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	opts, ok := reportOptions(w, r, 0)
+	if !ok {
 		return
 	}
 
-	report, err := repositories.GetTodayReport()
+	report, err := repositories.GetTodayReport(r.Context(), auth.TenantID(r), opts)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		writeError(w, r, err)
 		return
 	}
 
@@ -34,39 +45,432 @@ func TodayReportHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary		Get date range report
-// @Description	Get sales report for a specific date range including total revenue, total transactions, and best selling products
+// @Description	Get sales report for a specific date range including total revenue, total transactions, and best selling products. As CSV or XLSX (chosen by format or the Accept header) the report has one row per day plus a total row.
 // @Tags			reports
 // @Accept			json
 // @Produce		json
+// @Produce		text/csv
+// @Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security		ApiKeyAuth
 // @Param			start_date	query		string					true	"Start date (YYYY-MM-DD)"
 // @Param			end_date	query		string					true	"End date (YYYY-MM-DD)"
+// @Param			format		query		string					false	"Response format"	Enums(json, csv, xlsx)	default(json)
+// @Param			store_id	query		int						false	"Filter by store ID (omit for consolidated report)"
+// @Param			tz			query		string					false	"IANA timezone for day boundaries, e.g. Asia/Jakarta (default: store timezone)"
+// @Param			top			query		int						false	"Rank the top N products instead of listing all tied for first place"
+// @Param			sort_by		query		string					false	"Ranking key when top is set"	Enums(quantity, revenue)	default(quantity)
 // @Success		200			{object}	models.DateRangeReport	"Success"
-// @Failure		400			{object}	map[string]string		"Bad Request - Invalid date format"
-// @Failure		500			{object}	map[string]string		"Internal Server Error"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request - Invalid date format"
+// @Failure		404			{object}	models.ErrorResponse	"Store not found"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
 // @Router			/api/report [get]
 func DateRangeReportHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	startDate, endDate, ok := requireDateRange(w, r)
+	if !ok {
 		return
 	}
 
-	startDate := r.URL.Query().Get("start_date")
-	endDate := r.URL.Query().Get("end_date")
+	opts, ok := reportOptions(w, r, 0)
+	if !ok {
+		return
+	}
 
-	if startDate == "" || endDate == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "start_date and end_date are required"})
+	format, err := exportFormat(r)
+	if err != nil {
+		writeError(w, r, &repositories.ValidationError{Message: err.Error()})
+		return
+	}
+	if format != "" {
+		exportDateRangeReport(w, r, format, startDate, endDate, opts)
 		return
 	}
 
-	report, err := repositories.GetDateRangeReport(startDate, endDate)
+	report, err := repositories.GetDateRangeReport(r.Context(), auth.TenantID(r), startDate, endDate, opts)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid date format, use YYYY-MM-DD"})
+		writeError(w, r, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(report)
 }
+
+// exportDateRangeReport writes the daily totals of a date range report
+// followed by a total row.
+func exportDateRangeReport(w http.ResponseWriter, r *http.Request, format, startDate, endDate string, opts repositories.ReportOptions) {
+	series, err := repositories.GetSalesTimeSeries(r.Context(), auth.TenantID(r), startDate, endDate, "day", opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	header := []any{"tanggal", "total_revenue", "total_transaksi"}
+	name := "report-" + startDate + "-" + endDate
+
+	writeExport(w, r, format, name, header, func(out exports.Writer) error {
+		var revenue, transactions int
+		for _, b := range series.Data {
+			if err := out.WriteRow(b.Period.Format("2006-01-02"), b.TotalRevenue, b.TotalTransactions); err != nil {
+				return err
+			}
+			revenue += b.TotalRevenue
+			transactions += b.TotalTransactions
+		}
+		return out.WriteRow("TOTAL", revenue, transactions)
+	})
+}
+
+// @Summary		Get sales time series
+// @Description	Get revenue and transaction count per hour, day, week or month for a date range. Periods without sales are returned with zero values.
+// @Tags			reports
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			start_date	query		string					true	"Start date (YYYY-MM-DD)"
+// @Param			end_date	query		string					true	"End date (YYYY-MM-DD)"
+// @Param			granularity	query		string					false	"Bucket size"	Enums(hour, day, week, month)	default(day)
+// @Param			store_id	query		int						false	"Filter by store ID (omit for consolidated report)"
+// @Param			tz			query		string					false	"IANA timezone for day boundaries, e.g. Asia/Jakarta (default: store timezone)"
+// @Success		200			{object}	models.SalesTimeSeries	"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse	"Store not found"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/api/report/sales [get]
+func SalesReportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	startDate, endDate, ok := requireDateRange(w, r)
+	if !ok {
+		return
+	}
+
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = "day"
+	}
+
+	opts, ok := reportOptions(w, r, 0)
+	if !ok {
+		return
+	}
+
+	report, err := repositories.GetSalesTimeSeries(r.Context(), auth.TenantID(r), startDate, endDate, granularity, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// @Summary		Get product sales ranking
+// @Description	Rank products sold in a date range by quantity or revenue
+// @Tags			reports
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			start_date	query		string						true	"Start date (YYYY-MM-DD)"
+// @Param			end_date	query		string						true	"End date (YYYY-MM-DD)"
+// @Param			top			query		int							false	"Number of products"	default(10)
+// @Param			sort_by		query		string						false	"Ranking key"			Enums(quantity, revenue)	default(quantity)
+// @Param			store_id	query		int							false	"Filter by store ID (omit for consolidated report)"
+// @Param			tz			query		string						false	"IANA timezone for day boundaries, e.g. Asia/Jakarta (default: store timezone)"
+// @Success		200			{object}	models.ProductSalesReport	"Success"
+// @Failure		400			{object}	models.ErrorResponse		"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse		"Store not found"
+// @Failure		500			{object}	models.ErrorResponse		"Internal Server Error"
+// @Router			/api/report/products [get]
+func ProductSalesReportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	startDate, endDate, ok := requireDateRange(w, r)
+	if !ok {
+		return
+	}
+
+	opts, ok := reportOptions(w, r, 10)
+	if !ok {
+		return
+	}
+
+	report, err := repositories.GetProductSalesReport(r.Context(), auth.TenantID(r), startDate, endDate, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// @Summary		Get category sales report
+// @Description	Aggregate sales per product category in a date range, ranked by revenue or quantity
+// @Tags			reports
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			start_date	query		string						true	"Start date (YYYY-MM-DD)"
+// @Param			end_date	query		string						true	"End date (YYYY-MM-DD)"
+// @Param			top			query		int							false	"Number of categories"	default(10)
+// @Param			sort_by		query		string						false	"Ranking key"			Enums(quantity, revenue)	default(revenue)
+// @Param			store_id	query		int							false	"Filter by store ID (omit for consolidated report)"
+// @Param			tz			query		string						false	"IANA timezone for day boundaries, e.g. Asia/Jakarta (default: store timezone)"
+// @Success		200			{object}	models.CategorySalesReport	"Success"
+// @Failure		400			{object}	models.ErrorResponse		"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse		"Store not found"
+// @Failure		500			{object}	models.ErrorResponse		"Internal Server Error"
+// @Router			/api/report/categories [get]
+func CategorySalesReportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	startDate, endDate, ok := requireDateRange(w, r)
+	if !ok {
+		return
+	}
+
+	opts, ok := reportOptions(w, r, 10)
+	if !ok {
+		return
+	}
+
+	report, err := repositories.GetCategorySalesReport(r.Context(), auth.TenantID(r), startDate, endDate, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// @Summary		Get slow movers report
+// @Description	List products with zero or the least sales in a date range, with the stock still on hand
+// @Tags			reports
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			start_date	query		string					true	"Start date (YYYY-MM-DD)"
+// @Param			end_date	query		string					true	"End date (YYYY-MM-DD)"
+// @Param			top			query		int						false	"Number of products"	default(10)
+// @Param			store_id	query		int						false	"Filter by store ID (omit for consolidated report)"
+// @Param			tz			query		string					false	"IANA timezone for day boundaries, e.g. Asia/Jakarta (default: store timezone)"
+// @Success		200			{object}	models.SlowMoversReport	"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse	"Store not found"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/api/report/slow-movers [get]
+func SlowMoversReportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	startDate, endDate, ok := requireDateRange(w, r)
+	if !ok {
+		return
+	}
+
+	opts, ok := reportOptions(w, r, 10)
+	if !ok {
+		return
+	}
+
+	report, err := repositories.GetSlowMoversReport(r.Context(), auth.TenantID(r), startDate, endDate, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// @Summary		Get inventory valuation report
+// @Description	Value the stock on hand at cost and at selling price per product and per category, with stock aging buckets (0-30, 31-60, 61-90 and over 90 days since received)
+// @Tags			reports
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			store_id	query		int						false	"Filter by store ID (omit for all stores)"
+// @Param			tz			query		string					false	"IANA timezone for generated_at (default: store timezone)"
+// @Success		200			{object}	models.InventoryReport	"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse	"Store not found"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/api/report/inventory [get]
+func InventoryReportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	opts, ok := reportOptions(w, r, 0)
+	if !ok {
+		return
+	}
+
+	report, err := repositories.GetInventoryReport(r.Context(), auth.TenantID(r), opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// @Summary		Compare report periods
+// @Description	Get the report for a date range next to the previous period of the same length or the same dates last year, with absolute and percentage deltas for revenue, transaction count, average basket value and top products
+// @Tags			reports
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			start_date	query		string					true	"Start date (YYYY-MM-DD)"
+// @Param			end_date	query		string					true	"End date (YYYY-MM-DD)"
+// @Param			compare_to	query		string					false	"Period to compare with"		Enums(previous, last_year)	default(previous)
+// @Param			top			query		int						false	"Number of top products"		default(10)
+// @Param			sort_by		query		string					false	"Ranking key for top products"	Enums(quantity, revenue)	default(quantity)
+// @Param			store_id	query		int						false	"Filter by store ID (omit for consolidated report)"
+// @Param			tz			query		string					false	"IANA timezone for day boundaries, e.g. Asia/Jakarta (default: store timezone)"
+// @Success		200			{object}	models.ReportComparison	"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse	"Store not found"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/api/report/compare [get]
+func ReportComparisonHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	startDate, endDate, ok := requireDateRange(w, r)
+	if !ok {
+		return
+	}
+
+	opts, ok := reportOptions(w, r, 10)
+	if !ok {
+		return
+	}
+
+	report, err := repositories.GetReportComparison(r.Context(), auth.TenantID(r), startDate, endDate, r.URL.Query().Get("compare_to"), opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// @Summary		Get end-of-day report snapshots
+// @Description	Get the DailyReport frozen at closing time for a local date, one per store. Returns the latest revision unless a revision is given.
+// @Tags			reports
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			date		path		string					true	"Report date (YYYY-MM-DD)"
+// @Param			store_id	query		int						false	"Filter by store ID"
+// @Param			revision	query		int						false	"Revision number (default: latest)"
+// @Success		200			{array}		models.ReportSnapshot	"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse	"Not Found"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/api/report/snapshots/{date} [get]
+func ReportSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	date := r.PathValue("date")
+	storeID, ok := queryStoreID(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := queryID(w, r, "revision")
+	if !ok {
+		return
+	}
+
+	snapshots, err := repositories.GetReportSnapshots(r.Context(), auth.TenantID(r), date, storeID, revision)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if len(snapshots) == 0 {
+		utils.WriteError(w, http.StatusNotFound, models.ErrCodeNotFound, "no snapshot for "+date, nil)
+		return
+	}
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+// @Summary		Take report snapshots
+// @Description	Snapshot the DailyReport of a local date now, for one store or all stores. A store whose figures changed since its latest snapshot (a late correction) gets a new revision; unchanged stores return their latest revision.
+// @Tags			reports
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			date		path		string					true	"Report date (YYYY-MM-DD)"
+// @Param			store_id	query		int						false	"Store ID (default: all stores)"
+// @Success		200			{array}		models.ReportSnapshot	"No store changed"
+// @Success		201			{array}		models.ReportSnapshot	"At least one new revision stored"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse	"Store not found"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/api/report/snapshots/{date} [post]
+func CreateReportSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tenantID := auth.TenantID(r)
+	date := r.PathValue("date")
+	// CreateReportSnapshot answers an unknown store with 404.
+	storeID, ok := queryID(w, r, "store_id")
+	if !ok {
+		return
+	}
+
+	storeIDs := []int{storeID}
+	if storeID == 0 {
+		stores, err := repositories.GetAllStores(r.Context(), tenantID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		storeIDs = nil
+		for _, s := range stores {
+			storeIDs = append(storeIDs, s.ID)
+		}
+	}
+
+	var snapshots []models.ReportSnapshot
+	status := http.StatusOK
+	for _, id := range storeIDs {
+		snapshot, created, err := repositories.CreateReportSnapshot(r.Context(), tenantID, id, date)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if created {
+			status = http.StatusCreated
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+// reportOptions reads store_id, top, sort_by and tz from the query string.
+// defaultTop applies when top is absent. A malformed store_id is answered
+// with 400 and a store the tenant does not have with 404; ok is false then.
+func reportOptions(w http.ResponseWriter, r *http.Request, defaultTop int) (opts repositories.ReportOptions, ok bool) {
+	storeID, ok := queryStoreID(w, r)
+	if !ok {
+		return opts, false
+	}
+	top, err := strconv.Atoi(r.URL.Query().Get("top"))
+	if err != nil || top < 0 {
+		top = defaultTop
+	}
+
+	return repositories.ReportOptions{
+		StoreID:  storeID,
+		Top:      top,
+		SortBy:   r.URL.Query().Get("sort_by"),
+		Timezone: r.URL.Query().Get("tz"),
+	}, true
+}
+
+func requireDateRange(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	startDate := r.URL.Query().Get("start_date")
+	endDate := r.URL.Query().Get("end_date")
+
+	if startDate == "" || endDate == "" {
+		utils.WriteError(w, http.StatusBadRequest, models.ErrCodeValidation, "start_date and end_date are required", nil)
+		return "", "", false
+	}
+	return startDate, endDate, true
+}
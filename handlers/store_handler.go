@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"categories-api/auth"
+	"categories-api/models"
+	"categories-api/repositories"
+	"categories-api/utils"
+	"categories-api/validation"
+)
+
+// @Summary		List all stores
+// @Description	Get all stores/outlets with optional pagination
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			page	query		int						false	"Page number"		default(1)
+// @Param			limit	query		int						false	"Items per page"	default(10)
+// @Success		200		{object}	map[string]interface{}	"Success"
+// @Failure		500		{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/stores [get]
+func ListStoresHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	data, err := repositories.GetAllStores(r.Context(), auth.TenantID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	result := utils.Paginate(data, page, limit)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"page":  page,
+		"limit": limit,
+		"data":  result,
+	})
+}
+
+// @Summary		Create store
+// @Description	Create a new store/outlet
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			store	body		models.Store			true	"Store object"
+// @Success		201		{object}	models.Store			"Created"
+// @Failure		400		{object}	models.ErrorResponse	"Bad Request - Unknown timezone"
+// @Failure		500		{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/stores [post]
+func CreateStoreHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var store models.Store
+	if err := validation.Decode(w, r, &store); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	created, err := repositories.CreateStore(r.Context(), auth.TenantID(r), store)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// @Summary		Get store by ID
+// @Description	Get a single store by ID
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Store ID"
+// @Success		200	{object}	models.Store			"Success"
+// @Failure		400	{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Router			/stores/{id} [get]
+func GetStoreHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	store, err := repositories.GetStoreByID(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(store)
+}
+
+// @Summary		Update store
+// @Description	Update an existing store
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id		path		int						true	"Store ID"
+// @Param			store	body		models.Store			true	"Store object"
+// @Success		200		{object}	models.Store			"Success"
+// @Failure		400		{object}	models.ErrorResponse	"Bad Request - Unknown timezone"
+// @Failure		404		{object}	models.ErrorResponse	"Not Found"
+// @Router			/stores/{id} [put]
+func UpdateStoreHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var store models.Store
+	if err := validation.Decode(w, r, &store); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	updated, err := repositories.UpdateStore(r.Context(), auth.TenantID(r), id, store)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(updated)
+}
+
+// @Summary		Delete store
+// @Description	Delete a store by ID. A store that still holds stock or has transfers in transit cannot be deleted.
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path	int	true	"Store ID"
+// @Success		204	"No Content"
+// @Failure		400	{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Failure		409	{object}	models.ErrorResponse	"Conflict - Store has stock, transactions or transfers (details count stock and open transfers)"
+// @Router			/stores/{id} [delete]
+func DeleteStoreHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	err := repositories.DeleteStore(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary		Get store stock
+// @Description	Get stock levels of all products held by a store
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Store ID"
+// @Success		200	{array}		models.StoreStock		"Success"
+// @Failure		400	{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		500	{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/stores/{id}/stock [get]
+func StoreStockHandler(w http.ResponseWriter, r *http.Request) {
+	storeID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	stock, err := repositories.GetStoreStock(r.Context(), auth.TenantID(r), storeID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(stock)
+}
+
+// @Summary		Set store stock
+// @Description	Set the stock level of a product in a store, e.g. after a stock count
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id			path		int							true	"Store ID"
+// @Param			product_id	path		int							true	"Product ID"
+// @Param			request		body		models.StoreStockRequest	true	"New stock level"
+// @Success		200			{object}	models.StoreStock			"Success"
+// @Failure		400			{object}	models.ErrorResponse		"Bad Request - Validation error"
+// @Failure		500			{object}	models.ErrorResponse		"Internal Server Error"
+// @Router			/stores/{id}/stock/{product_id} [put]
+func SetStoreStockHandler(w http.ResponseWriter, r *http.Request) {
+	storeID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	productID, ok := pathID(w, r, "product_id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.StoreStockRequest
+	if err := validation.Decode(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	stock, err := repositories.SetStoreStock(r.Context(), auth.TenantID(r), storeID, productID, req.Stock)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(stock)
+}
+
+// @Summary		List stock transfers
+// @Description	Get all stock transfers between stores with pagination, newest first
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			page	query		int						false	"Page number"		default(1)
+// @Param			limit	query		int						false	"Items per page"	default(10)
+// @Success		200		{object}	map[string]interface{}	"Success"
+// @Failure		500		{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/transfers [get]
+func ListTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	data, err := repositories.GetAllTransfers(r.Context(), auth.TenantID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	result := utils.Paginate(data, page, limit)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"page":  page,
+		"limit": limit,
+		"data":  result,
+	})
+}
+
+// @Summary		Create stock transfer
+// @Description	Move stock out of the source store. The items stay in transit until the destination receives them.
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			request	body		models.StockTransferRequest	true	"Transfer request"
+// @Success		201		{object}	models.StockTransfer		"Transfer created"
+// @Failure		400		{object}	models.ErrorResponse		"Bad Request - Validation error"
+// @Failure		409		{object}	models.ErrorResponse		"Insufficient stock"
+// @Failure		500		{object}	models.ErrorResponse		"Internal Server Error"
+// @Router			/transfers [post]
+func CreateTransferHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.StockTransferRequest
+	if err := validation.Decode(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	transfer, err := repositories.CreateTransfer(r.Context(), auth.TenantID(r), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// @Summary		Get stock transfer by ID
+// @Description	Get a single stock transfer with its items
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Transfer ID"
+// @Success		200	{object}	models.StockTransfer	"Success"
+// @Failure		400	{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Router			/transfers/{id} [get]
+func GetTransferHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	transfer, err := repositories.GetTransferByID(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// @Summary		Receive stock transfer
+// @Description	Book the in-transit items into the destination store
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Transfer ID"
+// @Success		200	{object}	models.StockTransfer	"Success"
+// @Failure		409	{object}	models.ErrorResponse	"Conflict - Transfer not in transit"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Failure		500	{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/transfers/{id}/receive [post]
+func ReceiveTransferHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	transfer, err := repositories.ReceiveTransfer(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// @Summary		Cancel stock transfer
+// @Description	Return the in-transit items to the source store
+// @Tags			stores
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Transfer ID"
+// @Success		200	{object}	models.StockTransfer	"Success"
+// @Failure		409	{object}	models.ErrorResponse	"Conflict - Transfer not in transit"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Failure		500	{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/transfers/{id}/cancel [post]
+func CancelTransferHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	transfer, err := repositories.CancelTransfer(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(transfer)
+}
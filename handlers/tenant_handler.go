@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"categories-api/models"
+	"categories-api/repositories"
+	"categories-api/utils"
+	"categories-api/validation"
+)
+
+// @Summary		List all tenants
+// @Description	Get all tenants (merchants) hosted by this deployment. Requires the admin API key.
+// @Tags			tenants
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			page	query		int						false	"Page number"		default(1)
+// @Param			limit	query		int						false	"Items per page"	default(10)
+// @Success		200		{object}	map[string]interface{}	"Success"
+// @Failure		403		{object}	models.ErrorResponse	"Forbidden"
+// @Failure		500		{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/tenants [get]
+func ListTenantsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	data, err := repositories.GetAllTenants(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	result := utils.Paginate(data, page, limit)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"page":  page,
+		"limit": limit,
+		"data":  result,
+	})
+}
+
+// @Summary		Provision tenant
+// @Description	Create a tenant with its first store. The API key is only returned in this response.
+// @Tags			tenants
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			tenant	body		models.TenantRequest	true	"Tenant object"
+// @Success		201		{object}	models.TenantWithAPIKey	"Created"
+// @Failure		400		{object}	models.ErrorResponse	"Bad Request"
+// @Failure		403		{object}	models.ErrorResponse	"Forbidden"
+// @Failure		500		{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/tenants [post]
+func CreateTenantHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.TenantRequest
+	if err := validation.Decode(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	created, err := repositories.CreateTenant(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// @Summary		Get tenant by ID
+// @Description	Get a single tenant by ID. Requires the admin API key.
+// @Tags			tenants
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Tenant ID"
+// @Success		200	{object}	models.Tenant			"Success"
+// @Failure		400	{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Router			/tenants/{id} [get]
+func GetTenantHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	tenant, err := repositories.GetTenantByID(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(tenant)
+}
+
+// @Summary		Update tenant
+// @Description	Rename a tenant or (de)activate it. Inactive tenants are rejected at authentication.
+// @Tags			tenants
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id		path		int						true	"Tenant ID"
+// @Param			tenant	body		models.TenantRequest	true	"Tenant object"
+// @Success		200		{object}	models.Tenant			"Success"
+// @Failure		400		{object}	models.ErrorResponse	"Bad Request - Invalid ID or body"
+// @Failure		404		{object}	models.ErrorResponse	"Not Found"
+// @Router			/tenants/{id} [put]
+func UpdateTenantHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.TenantRequest
+	if err := validation.Decode(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	updated, err := repositories.UpdateTenant(r.Context(), id, req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(updated)
+}
+
+// @Summary		Rotate tenant API key
+// @Description	Issue a new API key for the tenant. The old key stops working immediately.
+// @Tags			tenants
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			id	path		int						true	"Tenant ID"
+// @Success		200	{object}	models.TenantWithAPIKey	"Success"
+// @Failure		400	{object}	models.ErrorResponse	"Bad Request - Invalid ID"
+// @Failure		404	{object}	models.ErrorResponse	"Not Found"
+// @Router			/tenants/{id}/rotate-key [post]
+func RotateTenantKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	rotated, err := repositories.RotateTenantAPIKey(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(rotated)
+}
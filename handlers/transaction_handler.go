@@ -4,83 +4,102 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"strings"
 
+	"categories-api/auth"
+	"categories-api/exports"
 	"categories-api/models"
 	"categories-api/repositories"
 	"categories-api/utils"
+	"categories-api/validation"
 )
 
 // @Summary		List all transactions
-// @Description	Get all transactions with pagination
+// @Description	Get all transactions with pagination, or export them as CSV or XLSX (chosen by format or the Accept header). Exports are streamed, unpaginated and can be limited by date range and store.
 // @Tags			transactions
 // @Accept			json
 // @Produce		json
-// @Param			page	query		int						false	"Page number"		default(1)
-// @Param			limit	query		int						false	"Items per page"	default(10)
-// @Success		200		{object}	map[string]interface{}	"Success"
-// @Failure		500		{object}	map[string]string		"Internal Server Error"
+// @Produce		text/csv
+// @Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security		ApiKeyAuth
+// @Param			page		query		int						false	"Page number"		default(1)
+// @Param			limit		query		int						false	"Items per page"	default(10)
+// @Param			format		query		string					false	"Response format"	Enums(json, csv, xlsx)	default(json)
+// @Param			start_date	query		string					false	"Export only: start date (YYYY-MM-DD)"
+// @Param			end_date	query		string					false	"Export only: end date (YYYY-MM-DD)"
+// @Param			store_id	query		int						false	"Export only: filter by store ID"
+// @Param			tz			query		string					false	"Export only: IANA timezone for dates (default: store timezone)"
+// @Success		200			{object}	map[string]interface{}	"Success"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse	"Store not found"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
 // @Router			/transactions [get]
-func TransactionsHandler(w http.ResponseWriter, r *http.Request) {
+func ListTransactionsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	switch r.Method {
-	case http.MethodGet:
-		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-
-		if page == 0 {
-			page = 1
-		}
-		if limit == 0 {
-			limit = 10
-		}
-
-		data := repositories.GetAllTransactions()
-		result := utils.Paginate(data, page, limit)
-
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"page":  page,
-			"limit": limit,
-			"data":  result,
-		})
+	format, err := exportFormat(r)
+	if err != nil {
+		writeError(w, r, &repositories.ValidationError{Message: err.Error()})
+		return
+	}
+	if format != "" {
+		exportTransactions(w, r, format)
+		return
+	}
 
-	case http.MethodPost:
-		//	@Summary		Create transaction (checkout)
-		//	@Description	Create a new transaction with multiple items. Validates stock and auto-decrements product stock.
-		//	@Tags			transactions
-		//	@Accept			json
-		//	@Produce		json
-		//	@Param			request	body		models.TransactionRequest		true	"Transaction request with items"
-		//	@Success		201		{object}	models.TransactionWithDetails	"Transaction created"
-		//	@Failure		400		{object}	map[string]interface{}			"Bad Request - Validation error"
-		//	@Failure		500		{object}	map[string]string				"Internal Server Error"
-		//	@Router			/transactions [post]
-		var req models.TransactionRequest
-		json.NewDecoder(r.Body).Decode(&req)
-
-		transaction, err := repositories.CreateTransaction(req.Items)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			if valErr, ok := err.(*repositories.ValidationError); ok {
-				json.NewEncoder(w).Encode(map[string]interface{}{
-					"error":      valErr.Message,
-					"product_id": valErr.ProductID,
-					"requested":  valErr.Requested,
-					"available":  valErr.Available,
-				})
-			} else {
-				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			}
-			return
-		}
-
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(transaction)
-
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
 	}
+
+	data, err := repositories.GetAllTransactions(r.Context(), auth.TenantID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	result := utils.Paginate(data, page, limit)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"page":  page,
+		"limit": limit,
+		"data":  result,
+	})
+}
+
+// @Summary		Create transaction (checkout)
+// @Description	Create a new transaction with multiple items. Validates stock and auto-decrements product stock.
+// @Tags			transactions
+// @Accept			json
+// @Produce		json
+// @Security		ApiKeyAuth
+// @Param			request	body		models.TransactionRequest		true	"Transaction request with items"
+// @Success		201		{object}	models.TransactionWithDetails	"Transaction created"
+// @Failure		400		{object}	models.ErrorResponse			"Bad Request - Validation error"
+// @Failure		409		{object}	models.ErrorResponse			"Insufficient stock"
+// @Failure		500		{object}	models.ErrorResponse			"Internal Server Error"
+// @Router			/transactions [post]
+func CreateTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.TransactionRequest
+	if err := validation.Decode(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	transaction, err := repositories.CreateTransaction(r.Context(), auth.TenantID(r), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transaction)
 }
 
 // @Summary		Get transaction by ID
@@ -88,26 +107,93 @@ func TransactionsHandler(w http.ResponseWriter, r *http.Request) {
 // @Tags			transactions
 // @Accept			json
 // @Produce		json
+// @Security		ApiKeyAuth
 // @Param			id	path		int								true	"Transaction ID"
 // @Success		200	{object}	models.TransactionWithDetails	"Success"
-// @Failure		404	{object}	map[string]string				"Not Found"
+// @Failure		400	{object}	models.ErrorResponse			"Bad Request - Invalid ID"
+// @Failure		404	{object}	models.ErrorResponse			"Not Found"
 // @Router			/transactions/{id} [get]
-func TransactionDetailHandler(w http.ResponseWriter, r *http.Request) {
+func GetTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	idStr := strings.TrimPrefix(r.URL.Path, "/transactions/")
-	id, _ := strconv.Atoi(idStr)
-
-	switch r.Method {
-	case http.MethodGet:
-		transaction, err := repositories.GetTransactionByID(id)
-		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			return
-		}
-		json.NewEncoder(w).Encode(transaction)
-
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+
+	transaction, err := repositories.GetTransactionByID(r.Context(), auth.TenantID(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(transaction)
+}
+
+func exportTransactions(w http.ResponseWriter, r *http.Request, format string) {
+	filter, ok := transactionFilter(w, r)
+	if !ok {
+		return
+	}
+	header := []any{"id", "created_at", "store_id", "total_amount", "payment_method", "paid_amount", "change_amount", "status"}
+
+	writeExport(w, r, format, "transactions", header, func(out exports.Writer) error {
+		return repositories.StreamTransactions(r.Context(), auth.TenantID(r), filter, func(t models.Transaction) error {
+			return out.WriteRow(t.ID, t.CreatedAt, t.StoreID, t.TotalAmount, t.PaymentMethod, t.PaidAmount, t.ChangeAmount, t.Status)
+		})
+	})
+}
+
+// @Summary		Export transaction lines
+// @Description	Stream every item sold in completed transactions, one row per product line with its transaction, store and category, as CSV (default) or XLSX
+// @Tags			transactions
+// @Produce		text/csv
+// @Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security		ApiKeyAuth
+// @Param			format		query		string					false	"Export format"	Enums(csv, xlsx)	default(csv)
+// @Param			start_date	query		string					false	"Start date (YYYY-MM-DD)"
+// @Param			end_date	query		string					false	"End date (YYYY-MM-DD)"
+// @Param			store_id	query		int						false	"Filter by store ID"
+// @Param			tz			query		string					false	"IANA timezone for dates (default: store timezone)"
+// @Success		200			{string}	string					"Export file"
+// @Failure		400			{object}	models.ErrorResponse	"Bad Request"
+// @Failure		404			{object}	models.ErrorResponse	"Store not found"
+// @Failure		500			{object}	models.ErrorResponse	"Internal Server Error"
+// @Router			/transactions/lines [get]
+func TransactionLinesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	format, err := exportFormat(r)
+	if err != nil {
+		writeError(w, r, &repositories.ValidationError{Message: err.Error()})
+		return
+	}
+	if format == "" {
+		format = exports.FormatCSV
+	}
+
+	filter, ok := transactionFilter(w, r)
+	if !ok {
+		return
+	}
+	header := []any{"transaction_id", "created_at", "store_id", "store_name", "payment_method", "product_id", "product_name", "category_name", "quantity", "price", "subtotal"}
+
+	writeExport(w, r, format, "transaction-lines", header, func(out exports.Writer) error {
+		return repositories.StreamTransactionLines(r.Context(), auth.TenantID(r), filter, func(l models.TransactionLine) error {
+			return out.WriteRow(l.TransactionID, l.CreatedAt, l.StoreID, l.StoreName, l.PaymentMethod, l.ProductID, l.ProductName, l.CategoryName, l.Quantity, l.Price, l.Subtotal)
+		})
+	})
+}
+
+// transactionFilter reads the filters of the transaction exports. A bad
+// store_id is answered with 400 or 404 and ok is false.
+func transactionFilter(w http.ResponseWriter, r *http.Request) (filter repositories.TransactionFilter, ok bool) {
+	storeID, ok := queryStoreID(w, r)
+	if !ok {
+		return filter, false
 	}
+	return repositories.TransactionFilter{
+		StartDate: r.URL.Query().Get("start_date"),
+		EndDate:   r.URL.Query().Get("end_date"),
+		StoreID:   storeID,
+		Timezone:  r.URL.Query().Get("tz"),
+	}, true
 }
@@ -1,28 +1,66 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"categories-api/auth"
 	"categories-api/database"
-	"categories-api/handlers"
+	"categories-api/middleware"
+	"categories-api/repositories"
+	"categories-api/router"
+	"categories-api/scheduler"
 
 	"github.com/spf13/viper"
 )
 
 //	@title			Categories API
 //	@version		1.0
-//	@description	RESTful API untuk kasir dengan Categories, Products, Transactions, dan Reports
+//	@description	RESTful API untuk kasir dengan Categories, Products, Stores, Transactions, dan Reports
 //	@host			localhost:8080
 //	@BasePath		/
 //	@schemes		http
 //	@produce		json
 //	@consume		json
 
+//	@securityDefinitions.apikey	ApiKeyAuth
+//	@in							header
+//	@name						X-API-Key
+
 // ubah Config
 type Config struct {
 	Port   string `mapstructure:"PORT"`
 	DBConn string `mapstructure:"DB_CONN"`
+
+	// HTTP server timeouts; see http.Server.
+	ReadHeaderTimeout time.Duration `mapstructure:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`
+	// ShutdownTimeout bounds the wait for in-flight requests after SIGINT
+	// or SIGTERM.
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+
+	// Query timeouts by route; see router.QueryTimeouts.
+	QueryTimeoutLookup time.Duration `mapstructure:"QUERY_TIMEOUT_LOOKUP"`
+	QueryTimeout       time.Duration `mapstructure:"QUERY_TIMEOUT"`
+	QueryTimeoutLong   time.Duration `mapstructure:"QUERY_TIMEOUT_LONG"`
+	QueryTimeoutExport time.Duration `mapstructure:"QUERY_TIMEOUT_EXPORT"`
+
+	// Connection pool; see database.PoolConfig.
+	DBMaxOpenConns    int           `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns    int           `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime time.Duration `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DBConnMaxIdleTime time.Duration `mapstructure:"DB_CONN_MAX_IDLE_TIME"`
 }
 
 func main() {
@@ -33,42 +71,228 @@ func main() {
 		log.Printf("Warning: .env file not found, using defaults: %v", err)
 	}
 
-	config := Config{
-		Port:   viper.GetString("PORT"),
-		DBConn: viper.GetString("DB_CONN"),
-	}
+	config := loadConfig()
 
 	if config.Port == "" {
 		config.Port = "8080"
 	}
 
-	err := database.InitDB()
+	logger := newLogger(viper.GetString("LOG_FORMAT"))
+	slog.SetDefault(logger)
+
+	err := database.InitDB(database.PoolConfig{
+		MaxOpenConns:    config.DBMaxOpenConns,
+		MaxIdleConns:    config.DBMaxIdleConns,
+		ConnMaxLifetime: config.DBConnMaxLifetime,
+		ConnMaxIdleTime: config.DBConnMaxIdleTime,
+	})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	defer database.Close()
 
-	// defer db.Close()
+	if len(os.Args) > 1 && os.Args[1] == "rebuild-summaries" {
+		rebuildSummaries(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "purge-deleted" {
+		purgeDeleted(os.Args[2:])
+		return
+	}
 
-	http.HandleFunc("/categories", handlers.CategoriesHandler)
-	http.HandleFunc("/categories/", handlers.CategoryDetailHandler)
-	http.HandleFunc("/products", handlers.ProductsHandler)
-	http.HandleFunc("/products/", handlers.ProductDetailHandler)
-	http.HandleFunc("/transactions", handlers.TransactionsHandler)
-	http.HandleFunc("/transactions/", handlers.TransactionDetailHandler)
-	http.HandleFunc("/api/report/hari-ini", handlers.TodayReportHandler)
-	http.HandleFunc("/api/report", handlers.DateRangeReportHandler)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	http.HandleFunc("/swagger", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "docs/index.html")
-	})
-	http.HandleFunc("/swagger/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "docs/index.html")
-	})
-	http.HandleFunc("/swagger/doc.json", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "docs/swagger.json")
-	})
+	var snapshotsStopped <-chan struct{}
+	if closingTime := viper.GetString("EOD_SNAPSHOT_TIME"); closingTime != "" {
+		snapshotsStopped, err = scheduler.StartEndOfDaySnapshots(ctx, closingTime)
+		if err != nil {
+			log.Fatalf("Failed to start end-of-day snapshots: %v", err)
+		}
+		log.Printf("End-of-day report snapshots scheduled at %s store time", closingTime)
+	}
 
-	log.Printf("Server running on :%s", config.Port)
-	log.Printf("Swagger UI available at http://localhost:%s/swagger", config.Port)
-	log.Fatal(http.ListenAndServe(":"+config.Port, nil))
+	server := &http.Server{
+		Addr: ":" + config.Port,
+		Handler: middleware.Chain(router.New(router.QueryTimeouts{
+			Lookup:  config.QueryTimeoutLookup,
+			Default: config.QueryTimeout,
+			Long:    config.QueryTimeoutLong,
+			Export:  config.QueryTimeoutExport,
+		}),
+			middleware.RequestID,
+			middleware.AccessLog(logger),
+			middleware.Recover(logger),
+			middleware.CORS(corsOptions()),
+			middleware.Gzip,
+			auth.Middleware,
+		),
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+
+	go func() {
+		log.Printf("Server running on :%s", config.Port)
+		log.Printf("Swagger UI available at http://localhost:%s/swagger", config.Port)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	// A second signal kills the process without waiting.
+	stop()
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", config.ShutdownTimeout)
+
+	// Shutdown stops accepting connections and waits for running handlers,
+	// so a checkout in progress commits before the database is closed.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown did not complete: %v", err)
+	}
+	if snapshotsStopped != nil {
+		select {
+		case <-snapshotsStopped:
+		case <-shutdownCtx.Done():
+		}
+	}
+	log.Printf("Server stopped")
+}
+
+// loadConfig reads Config from viper, falling back to defaults suited to a
+// single server. Durations take a unit, e.g. 15s or 30m.
+func loadConfig() Config {
+	viper.SetDefault("HTTP_READ_HEADER_TIMEOUT", 5*time.Second)
+	viper.SetDefault("HTTP_READ_TIMEOUT", 30*time.Second)
+	viper.SetDefault("HTTP_WRITE_TIMEOUT", 60*time.Second)
+	viper.SetDefault("HTTP_IDLE_TIMEOUT", 120*time.Second)
+	viper.SetDefault("SHUTDOWN_TIMEOUT", 30*time.Second)
+	viper.SetDefault("QUERY_TIMEOUT_LOOKUP", 3*time.Second)
+	viper.SetDefault("QUERY_TIMEOUT", 10*time.Second)
+	viper.SetDefault("QUERY_TIMEOUT_LONG", 60*time.Second)
+	viper.SetDefault("QUERY_TIMEOUT_EXPORT", 0)
+	viper.SetDefault("DB_MAX_OPEN_CONNS", 25)
+	viper.SetDefault("DB_MAX_IDLE_CONNS", 5)
+	viper.SetDefault("DB_CONN_MAX_LIFETIME", 30*time.Minute)
+	viper.SetDefault("DB_CONN_MAX_IDLE_TIME", 5*time.Minute)
+
+	return Config{
+		Port:              viper.GetString("PORT"),
+		DBConn:            viper.GetString("DB_CONN"),
+		ReadHeaderTimeout: viper.GetDuration("HTTP_READ_HEADER_TIMEOUT"),
+		ReadTimeout:       viper.GetDuration("HTTP_READ_TIMEOUT"),
+		WriteTimeout:      viper.GetDuration("HTTP_WRITE_TIMEOUT"),
+		IdleTimeout:       viper.GetDuration("HTTP_IDLE_TIMEOUT"),
+		ShutdownTimeout:   viper.GetDuration("SHUTDOWN_TIMEOUT"),
+
+		QueryTimeoutLookup: viper.GetDuration("QUERY_TIMEOUT_LOOKUP"),
+		QueryTimeout:       viper.GetDuration("QUERY_TIMEOUT"),
+		QueryTimeoutLong:   viper.GetDuration("QUERY_TIMEOUT_LONG"),
+		QueryTimeoutExport: viper.GetDuration("QUERY_TIMEOUT_EXPORT"),
+
+		DBMaxOpenConns:    viper.GetInt("DB_MAX_OPEN_CONNS"),
+		DBMaxIdleConns:    viper.GetInt("DB_MAX_IDLE_CONNS"),
+		DBConnMaxLifetime: viper.GetDuration("DB_CONN_MAX_LIFETIME"),
+		DBConnMaxIdleTime: viper.GetDuration("DB_CONN_MAX_IDLE_TIME"),
+	}
+}
+
+// newLogger logs as JSON lines when format is "json" and as key=value text
+// otherwise.
+func newLogger(format string) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	}
+	return slog.New(slog.NewTextHandler(os.Stdout, nil))
+}
+
+// corsOptions reads CORS_ALLOWED_ORIGINS (comma separated, "*" for any) and
+// CORS_MAX_AGE (seconds). CORS stays off while no origin is configured.
+func corsOptions() middleware.CORSOptions {
+	var origins []string
+	for _, origin := range strings.Split(viper.GetString("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	maxAge := 600
+	if viper.IsSet("CORS_MAX_AGE") {
+		maxAge = viper.GetInt("CORS_MAX_AGE")
+	}
+
+	return middleware.CORSOptions{
+		AllowedOrigins: origins,
+		MaxAge:         time.Duration(maxAge) * time.Second,
+	}
+}
+
+// rebuildSummaries recomputes the daily sales summaries from the raw
+// transactions: go run . rebuild-summaries [-tenant ID]
+func rebuildSummaries(args []string) {
+	flags := flag.NewFlagSet("rebuild-summaries", flag.ExitOnError)
+	tenantID := flags.Int("tenant", 0, "rebuild only this tenant (default: all tenants)")
+	flags.Parse(args)
+
+	ctx := context.Background()
+	var tenantIDs []int
+	if *tenantID != 0 {
+		tenantIDs = append(tenantIDs, *tenantID)
+	} else {
+		tenants, err := repositories.GetAllTenants(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list tenants: %v", err)
+		}
+		for _, t := range tenants {
+			tenantIDs = append(tenantIDs, t.ID)
+		}
+	}
+
+	for _, id := range tenantIDs {
+		if err := repositories.RebuildDailySummaries(ctx, id); err != nil {
+			log.Fatalf("Failed to rebuild daily summaries for tenant %d: %v", id, err)
+		}
+		log.Printf("Rebuilt daily summaries for tenant %d", id)
+	}
+}
+
+// purgeDeleted permanently removes products and categories that were
+// deleted longer ago than the retention period:
+// go run . purge-deleted [-tenant ID] [-days N]
+func purgeDeleted(args []string) {
+	retentionDays := 30
+	if viper.IsSet("SOFT_DELETE_RETENTION_DAYS") {
+		retentionDays = viper.GetInt("SOFT_DELETE_RETENTION_DAYS")
+	}
+
+	flags := flag.NewFlagSet("purge-deleted", flag.ExitOnError)
+	tenantID := flags.Int("tenant", 0, "purge only this tenant (default: all tenants)")
+	days := flags.Int("days", retentionDays, "purge rows deleted more than this many days ago")
+	flags.Parse(args)
+
+	ctx := context.Background()
+	var tenantIDs []int
+	if *tenantID != 0 {
+		tenantIDs = append(tenantIDs, *tenantID)
+	} else {
+		tenants, err := repositories.GetAllTenants(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list tenants: %v", err)
+		}
+		for _, t := range tenants {
+			tenantIDs = append(tenantIDs, t.ID)
+		}
+	}
+
+	before := time.Now().AddDate(0, 0, -*days)
+	for _, id := range tenantIDs {
+		result, err := repositories.PurgeDeleted(ctx, id, before)
+		if err != nil {
+			log.Fatalf("Failed to purge deleted rows for tenant %d: %v", id, err)
+		}
+		log.Printf("Purged %d products and %d categories deleted before %s for tenant %d", result.Products, result.Categories, before.Format(time.DateOnly), id)
+	}
 }
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures CORS. Empty method, header and exposed-header
+// lists fall back to what the API uses.
+type CORSOptions struct {
+	// AllowedOrigins lists the origins browsers may call from, e.g.
+	// https://pos.example.com. "*" allows any origin. Empty disables CORS.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+var (
+	defaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "If-Match", "If-None-Match", RequestIDHeader}
+	defaultCORSExposed = []string{RequestIDHeader, "Content-Disposition", "ETag"}
+)
+
+// CORS answers preflight requests itself, before authentication, and adds
+// the Access-Control headers to responses for allowed origins.
+func CORS(opts CORSOptions) Middleware {
+	if len(opts.AllowedMethods) == 0 {
+		opts.AllowedMethods = defaultCORSMethods
+	}
+	if len(opts.AllowedHeaders) == 0 {
+		opts.AllowedHeaders = defaultCORSHeaders
+	}
+	if len(opts.ExposedHeaders) == 0 {
+		opts.ExposedHeaders = defaultCORSExposed
+	}
+	anyOrigin := slices.Contains(opts.AllowedOrigins, "*")
+	methods := strings.Join(opts.AllowedMethods, ", ")
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+	exposed := strings.Join(opts.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		if len(opts.AllowedOrigins) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			allowed := anyOrigin || slices.Contains(opts.AllowedOrigins, origin)
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if allowed {
+				if anyOrigin {
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				} else {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+			}
+
+			if preflight {
+				// Without the Allow-* headers the browser blocks the real
+				// request, which is all a refused origin needs.
+				if allowed {
+					w.Header().Add("Vary", "Access-Control-Request-Method")
+					w.Header().Add("Vary", "Access-Control-Request-Headers")
+					w.Header().Set("Access-Control-Allow-Methods", methods)
+					w.Header().Set("Access-Control-Allow-Headers", headers)
+					if opts.MaxAge > 0 {
+						w.Header().Set("Access-Control-Max-Age", maxAge)
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if allowed {
+				w.Header().Set("Access-Control-Expose-Headers", exposed)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
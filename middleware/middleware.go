@@ -0,0 +1,55 @@
+// Package middleware holds the HTTP middleware wrapped around the router:
+// request IDs, access logs, panic recovery, CORS and gzip compression.
+package middleware
+
+import (
+	"net/http"
+)
+
+// Middleware wraps a handler with extra behaviour.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with the given middleware. The first one is the outermost,
+// so it sees the request first and the response last.
+func Chain(h http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// statusWriter remembers the status code and the number of body bytes
+// written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps streamed exports streaming through the wrapper.
+func (s *statusWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
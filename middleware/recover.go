@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"categories-api/models"
+	"categories-api/utils"
+)
+
+// Recover turns a panicking handler into a JSON 500 and logs the panic with
+// its stack and request ID. If the handler had already started the response
+// the status cannot change any more and the client gets a truncated body.
+func Recover(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				logger.ErrorContext(r.Context(), "panic",
+					slog.String("request_id", RequestIDFrom(r.Context())),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("error", fmt.Sprint(rec)),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				if sw.status == 0 {
+					w.Header().Del("Content-Encoding")
+					w.Header().Del("Content-Disposition")
+					utils.WriteError(w, http.StatusInternalServerError, models.ErrCodeInternal, "internal server error", nil)
+				}
+			}()
+
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+// ProductBulkUpdate applies the same change to every product in IDs. Price
+// and PriceChangePct are mutually exclusive; fields left out are unchanged.
+type ProductBulkUpdate struct {
+	IDs            []int    `json:"ids" example:"1,2,3" validate:"required,max=1000"`
+	Price          *int     `json:"price,omitempty" example:"4000" validate:"min=1"`
+	PriceChangePct *float64 `json:"price_change_pct,omitempty" example:"10"`
+	CategoriesID   *int     `json:"categories_id,omitempty" example:"2" validate:"min=1"`
+	Stock          *int     `json:"stock,omitempty" example:"50" validate:"min=0"`
+}
+
+type BulkDelete struct {
+	IDs []int `json:"ids" example:"1,2,3" validate:"required,max=1000"`
+}
+
+// BulkItemResult reports one item of a bulk request. Action and Product are
+// only set when the change was saved.
+type BulkItemResult struct {
+	ID      int      `json:"id" example:"1"`
+	Action  string   `json:"action,omitempty" example:"update"`
+	Product *Product `json:"product,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// BulkResult reports every item of a bulk request. Nothing is saved when
+// Failed is not zero; Succeeded then counts the items that passed their
+// checks.
+type BulkResult struct {
+	Total     int              `json:"total" example:"3"`
+	Succeeded int              `json:"succeeded" example:"3"`
+	Failed    int              `json:"failed" example:"0"`
+	Results   []BulkItemResult `json:"results"`
+}
@@ -4,8 +4,13 @@ import "time"
 
 type Category struct {
 	ID          int       `json:"id" example:"1"`
-	Name        string    `json:"name" example:"Makanan"`
+	Name        string    `json:"name" example:"Makanan" validate:"required,max=255"`
 	Description string    `json:"description" example:"Kategori makanan"`
 	CreatedAt   time.Time `json:"created_at" example:"2026-02-10T10:00:00Z"`
 	UpdatedAt   time.Time `json:"updated_at" example:"2026-02-10T10:00:00Z"`
+	// Version grows with every change and is sent as the ETag. Read-only.
+	Version int `json:"version" example:"2"`
+	// DeletedAt is only set on deleted categories listed with
+	// include_deleted=true; they can be restored until purged.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" example:"2026-02-12T08:00:00Z"`
 }
@@ -0,0 +1,30 @@
+package models
+
+// Error codes returned in ErrorResponse. Clients should branch on the code,
+// not on the message.
+const (
+	ErrCodeValidation           = "validation_error"
+	ErrCodeNotFound             = "not_found"
+	ErrCodeConflict             = "conflict"
+	ErrCodeInsufficientStock    = "insufficient_stock"
+	ErrCodeUnauthorized         = "unauthorized"
+	ErrCodeForbidden            = "forbidden"
+	ErrCodeMethodNotAllowed     = "method_not_allowed"
+	ErrCodeRequestTooLarge      = "request_too_large"
+	ErrCodePreconditionFailed   = "precondition_failed"
+	ErrCodePreconditionRequired = "precondition_required"
+	ErrCodeTimeout              = "timeout"
+	ErrCodeInternal             = "internal_error"
+)
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+type APIError struct {
+	Code      string `json:"code" example:"not_found"`
+	Message   string `json:"message" example:"product 42 not found"`
+	Details   any    `json:"details,omitempty" swaggertype:"object"`
+	RequestID string `json:"request_id,omitempty" example:"4f1c2a9e0b7d4e6f8a3b5c7d9e1f2a3b"`
+}
@@ -1,9 +1,65 @@
 package models
 
+import "time"
+
 type Product struct {
 	ID           int    `json:"id" example:"1"`
-	Name         string `json:"name" example:"Indomie Goreng"`
-	Price        int    `json:"price" example:"3500"`
-	Stock        int    `json:"stock" example:"100"`
-	CategoriesID int    `json:"categories_id" example:"1"`
+	SKU          string `json:"sku" example:"IDM-GRG-85" validate:"max=64"`
+	Name         string `json:"name" example:"Indomie Goreng" validate:"required,max=255"`
+	Price        int    `json:"price" example:"3500" validate:"min=1"`
+	Cost         int    `json:"cost" example:"2800" validate:"min=0"`
+	Stock        int    `json:"stock" example:"100" validate:"min=0"`
+	CategoriesID int    `json:"categories_id" example:"1" validate:"required"`
+	Barcode      string `json:"barcode" example:"089686010947" validate:"max=64"`
+	// Archived products keep their sales history but are hidden from the
+	// catalog and cannot be sold. Set through the archive endpoints only.
+	Archived bool `json:"archived" example:"false"`
+	// Version grows with every change, stock included, and is sent as the
+	// ETag. Read-only.
+	Version int `json:"version" example:"3"`
+	// DeletedAt is only set on deleted products listed with
+	// include_deleted=true; they can be restored until purged.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" example:"2026-02-12T08:00:00Z"`
+}
+
+// ProductSuggestion is the compact product shown while a cashier types.
+type ProductSuggestion struct {
+	ID      int    `json:"id" example:"1"`
+	Name    string `json:"name" example:"Indomie Goreng"`
+	Price   int    `json:"price" example:"3500"`
+	Stock   int    `json:"stock" example:"100"`
+	Barcode string `json:"barcode" example:"089686010947"`
+}
+
+// ProductImportRow is one product read from an import file. Row is the line
+// it came from; Stock is nil when the file leaves the stock unchanged.
+type ProductImportRow struct {
+	Row      int      `json:"-"`
+	SKU      string   `json:"sku" example:"IDM-GRG-85"`
+	Name     string   `json:"name" example:"Indomie Goreng"`
+	Price    int      `json:"price" example:"3500"`
+	Cost     int      `json:"cost" example:"2800"`
+	Stock    *int     `json:"stock" example:"100"`
+	Category string   `json:"category" example:"Makanan"`
+	Errors   []string `json:"-"`
+}
+
+type ProductImportRowResult struct {
+	Row       int      `json:"row" example:"2"`
+	SKU       string   `json:"sku" example:"IDM-GRG-85"`
+	Action    string   `json:"action,omitempty" example:"create"`
+	ProductID int      `json:"product_id,omitempty" example:"1"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// ProductImportResult reports what an import did, or would do on a dry run.
+// Nothing is saved when Failed is not zero.
+type ProductImportResult struct {
+	DryRun            bool                     `json:"dry_run" example:"false"`
+	Total             int                      `json:"total" example:"2"`
+	Created           int                      `json:"created" example:"1"`
+	Updated           int                      `json:"updated" example:"1"`
+	Failed            int                      `json:"failed" example:"0"`
+	CategoriesCreated []string                 `json:"categories_created"`
+	Rows              []ProductImportRowResult `json:"rows"`
 }
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type ReceiptItem struct {
+	ProductID int    `json:"product_id" example:"1"`
+	Name      string `json:"name" example:"Indomie Goreng"`
+	Quantity  int    `json:"quantity" example:"2"`
+	Price     int    `json:"price" example:"3500"`
+	Subtotal  int    `json:"subtotal" example:"7000"`
+}
+
+type Receipt struct {
+	StoreName     string        `json:"store_name" example:"Toko Makmur"`
+	StoreAddress  string        `json:"store_address" example:"Jl. Merdeka No. 1"`
+	StorePhone    string        `json:"store_phone" example:"021-123456"`
+	Footer        string        `json:"footer" example:"Terima kasih"`
+	TransactionID int           `json:"transaction_id" example:"1"`
+	CreatedAt     time.Time     `json:"created_at" example:"2026-02-10T10:00:00Z"`
+	Items         []ReceiptItem `json:"items"`
+	TotalAmount   int           `json:"total_amount" example:"35000"`
+	PaymentMethod string        `json:"payment_method" example:"cash"`
+	PaidAmount    int           `json:"paid_amount" example:"50000"`
+	ChangeAmount  int           `json:"change_amount" example:"15000"`
+}
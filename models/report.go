@@ -1,14 +1,28 @@
 package models
 
+import "time"
+
 type BestSellingProduct struct {
-	Name    string `json:"nama" example:"Indomie Goreng"`
-	QtySold int    `json:"qty_terjual" example:"15"`
+	ProductID int    `json:"product_id" example:"1"`
+	Name      string `json:"nama" example:"Indomie Goreng"`
+	QtySold   int    `json:"qty_terjual" example:"15"`
+	Revenue   int    `json:"revenue" example:"52500"`
+}
+
+type StoreSales struct {
+	StoreID           int    `json:"store_id" example:"1"`
+	StoreName         string `json:"store_name" example:"Outlet Pusat"`
+	TotalRevenue      int    `json:"total_revenue" example:"30000"`
+	TotalTransactions int    `json:"total_transaksi" example:"3"`
 }
 
 type DailyReport struct {
+	StoreID             int                  `json:"store_id,omitempty" example:"1"`
+	Timezone            string               `json:"timezone" example:"Asia/Jakarta"`
 	TotalRevenue        int                  `json:"total_revenue" example:"50000"`
 	TotalTransactions   int                  `json:"total_transaksi" example:"5"`
 	BestSellingProducts []BestSellingProduct `json:"produk_terlaris"`
+	Stores              []StoreSales         `json:"stores,omitempty"`
 }
 
 type DateRangeReport struct {
@@ -16,3 +30,149 @@ type DateRangeReport struct {
 	StartDate string `json:"start_date" example:"2026-01-01"`
 	EndDate   string `json:"end_date" example:"2026-02-01"`
 }
+
+type SalesBucket struct {
+	Period            time.Time `json:"period" example:"2026-02-10T00:00:00Z"`
+	TotalRevenue      int       `json:"total_revenue" example:"50000"`
+	TotalTransactions int       `json:"total_transaksi" example:"5"`
+}
+
+type SalesTimeSeries struct {
+	StartDate   string        `json:"start_date" example:"2026-02-01"`
+	EndDate     string        `json:"end_date" example:"2026-02-07"`
+	Granularity string        `json:"granularity" example:"day"`
+	StoreID     int           `json:"store_id,omitempty" example:"1"`
+	Timezone    string        `json:"timezone" example:"Asia/Jakarta"`
+	Data        []SalesBucket `json:"data"`
+}
+
+type ProductSales struct {
+	ProductID  int    `json:"product_id" example:"1"`
+	Name       string `json:"nama" example:"Indomie Goreng"`
+	CategoryID int    `json:"category_id" example:"1"`
+	QtySold    int    `json:"qty_terjual" example:"15"`
+	Revenue    int    `json:"revenue" example:"52500"`
+}
+
+type ProductSalesReport struct {
+	StartDate string         `json:"start_date" example:"2026-02-01"`
+	EndDate   string         `json:"end_date" example:"2026-02-07"`
+	StoreID   int            `json:"store_id,omitempty" example:"1"`
+	Timezone  string         `json:"timezone" example:"Asia/Jakarta"`
+	SortBy    string         `json:"sort_by" example:"quantity"`
+	Top       int            `json:"top" example:"10"`
+	Data      []ProductSales `json:"data"`
+}
+
+type CategorySales struct {
+	CategoryID        int    `json:"category_id" example:"1"`
+	Name              string `json:"nama" example:"Makanan"`
+	QtySold           int    `json:"qty_terjual" example:"42"`
+	Revenue           int    `json:"revenue" example:"147000"`
+	TotalTransactions int    `json:"total_transaksi" example:"12"`
+}
+
+type CategorySalesReport struct {
+	StartDate string          `json:"start_date" example:"2026-02-01"`
+	EndDate   string          `json:"end_date" example:"2026-02-07"`
+	StoreID   int             `json:"store_id,omitempty" example:"1"`
+	Timezone  string          `json:"timezone" example:"Asia/Jakarta"`
+	SortBy    string          `json:"sort_by" example:"revenue"`
+	Top       int             `json:"top" example:"10"`
+	Data      []CategorySales `json:"data"`
+}
+
+type SlowMovingProduct struct {
+	ProductSales
+	Stock int `json:"stock" example:"80"`
+}
+
+type SlowMoversReport struct {
+	StartDate string              `json:"start_date" example:"2026-02-01"`
+	EndDate   string              `json:"end_date" example:"2026-02-07"`
+	StoreID   int                 `json:"store_id,omitempty" example:"1"`
+	Timezone  string              `json:"timezone" example:"Asia/Jakarta"`
+	Top       int                 `json:"top" example:"10"`
+	Data      []SlowMovingProduct `json:"data"`
+}
+
+// MetricDelta compares one figure between two periods. ChangePct is nil when
+// the previous value is zero.
+type MetricDelta struct {
+	Current   int      `json:"current" example:"350000"`
+	Previous  int      `json:"previous" example:"280000"`
+	Change    int      `json:"change" example:"70000"`
+	ChangePct *float64 `json:"change_pct" example:"25"`
+}
+
+type ReportDelta struct {
+	TotalRevenue      MetricDelta `json:"total_revenue"`
+	TotalTransactions MetricDelta `json:"total_transaksi"`
+	AverageBasket     MetricDelta `json:"average_basket"`
+}
+
+type ProductComparison struct {
+	ProductID int         `json:"product_id" example:"1"`
+	Name      string      `json:"nama" example:"Indomie Goreng"`
+	QtySold   MetricDelta `json:"qty_terjual"`
+	Revenue   MetricDelta `json:"revenue"`
+}
+
+type ReportComparison struct {
+	CompareTo   string              `json:"compare_to" example:"previous"`
+	Current     DateRangeReport     `json:"current"`
+	Previous    DateRangeReport     `json:"previous"`
+	Delta       ReportDelta         `json:"delta"`
+	TopProducts []ProductComparison `json:"top_products"`
+}
+
+// StockAging splits stock on hand by how many days ago it was received.
+type StockAging struct {
+	Days0To30  int `json:"0_30" example:"40"`
+	Days31To60 int `json:"31_60" example:"20"`
+	Days61To90 int `json:"61_90" example:"0"`
+	Over90     int `json:"over_90" example:"5"`
+}
+
+type ProductValuation struct {
+	ProductID   int        `json:"product_id" example:"1"`
+	Name        string     `json:"nama" example:"Indomie Goreng"`
+	CategoryID  int        `json:"category_id" example:"1"`
+	Stock       int        `json:"stock" example:"65"`
+	Cost        int        `json:"cost" example:"2800"`
+	Price       int        `json:"price" example:"3500"`
+	CostValue   int        `json:"cost_value" example:"182000"`
+	RetailValue int        `json:"retail_value" example:"227500"`
+	Aging       StockAging `json:"aging"`
+}
+
+type CategoryValuation struct {
+	CategoryID  int        `json:"category_id" example:"1"`
+	Name        string     `json:"nama" example:"Makanan"`
+	Stock       int        `json:"stock" example:"65"`
+	CostValue   int        `json:"cost_value" example:"182000"`
+	RetailValue int        `json:"retail_value" example:"227500"`
+	Aging       StockAging `json:"aging"`
+}
+
+type InventoryReport struct {
+	StoreID          int                 `json:"store_id,omitempty" example:"1"`
+	GeneratedAt      time.Time           `json:"generated_at" example:"2026-02-10T10:00:00Z"`
+	TotalStock       int                 `json:"total_stock" example:"65"`
+	TotalCostValue   int                 `json:"total_cost_value" example:"182000"`
+	TotalRetailValue int                 `json:"total_retail_value" example:"227500"`
+	Aging            StockAging          `json:"aging"`
+	Categories       []CategoryValuation `json:"categories"`
+	Products         []ProductValuation  `json:"products"`
+}
+
+// ReportSnapshot is a store's DailyReport frozen at closing time. Snapshots
+// are never changed; a correction is stored as the next revision.
+type ReportSnapshot struct {
+	ID         int         `json:"id" example:"1"`
+	StoreID    int         `json:"store_id" example:"1"`
+	ReportDate string      `json:"report_date" example:"2026-02-10"`
+	Revision   int         `json:"revision" example:"1"`
+	CreatedAt  time.Time   `json:"created_at" example:"2026-02-10T22:00:00Z"`
+	Report     DailyReport `json:"report"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+type Store struct {
+	ID        int       `json:"id" example:"1"`
+	Name      string    `json:"name" example:"Outlet Pusat" validate:"required,max=255"`
+	Address   string    `json:"address" example:"Jl. Merdeka No. 1"`
+	Phone     string    `json:"phone" example:"021-123456" validate:"max=50"`
+	Timezone  string    `json:"timezone" example:"Asia/Jakarta" validate:"max=64"`
+	CreatedAt time.Time `json:"created_at" example:"2026-02-10T10:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2026-02-10T10:00:00Z"`
+}
+
+type StoreStock struct {
+	StoreID     int    `json:"store_id" example:"1"`
+	ProductID   int    `json:"product_id" example:"1"`
+	ProductName string `json:"product_name" example:"Indomie Goreng"`
+	Stock       int    `json:"stock" example:"40"`
+}
+
+type StoreStockRequest struct {
+	Stock int `json:"stock" example:"40" validate:"min=0"`
+}
+
+const (
+	TransferStatusInTransit = "in_transit"
+	TransferStatusReceived  = "received"
+	TransferStatusCancelled = "cancelled"
+)
+
+type StockTransferItem struct {
+	ProductID int `json:"product_id" example:"1" validate:"required"`
+	Quantity  int `json:"quantity" example:"10" validate:"min=1"`
+}
+
+type StockTransfer struct {
+	ID          int                 `json:"id" example:"1"`
+	FromStoreID int                 `json:"from_store_id" example:"1"`
+	ToStoreID   int                 `json:"to_store_id" example:"2"`
+	Status      string              `json:"status" example:"in_transit"`
+	Note        string              `json:"note" example:"Restock mingguan"`
+	CreatedAt   time.Time           `json:"created_at" example:"2026-02-10T10:00:00Z"`
+	ReceivedAt  *time.Time          `json:"received_at,omitempty" example:"2026-02-11T09:00:00Z"`
+	Items       []StockTransferItem `json:"items"`
+}
+
+type StockTransferRequest struct {
+	FromStoreID int                 `json:"from_store_id" example:"1" validate:"required"`
+	ToStoreID   int                 `json:"to_store_id" example:"2" validate:"required"`
+	Note        string              `json:"note" example:"Restock mingguan"`
+	Items       []StockTransferItem `json:"items" validate:"required,max=500"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type Tenant struct {
+	ID        int       `json:"id" example:"1"`
+	Name      string    `json:"name" example:"Toko Makmur"`
+	Active    bool      `json:"active" example:"true"`
+	CreatedAt time.Time `json:"created_at" example:"2026-02-10T10:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2026-02-10T10:00:00Z"`
+}
+
+// TenantWithAPIKey is returned only when a key is issued; the key itself is
+// never stored, so it cannot be retrieved again.
+type TenantWithAPIKey struct {
+	Tenant
+	APIKey string `json:"api_key" example:"3f6c0a9e5d..."`
+}
+
+type TenantRequest struct {
+	Name   string `json:"name" example:"Toko Makmur" validate:"max=255"`
+	Active *bool  `json:"active,omitempty" example:"true"`
+}
@@ -3,10 +3,14 @@ package models
 import "time"
 
 type Transaction struct {
-	ID          int       `json:"id" example:"1"`
-	TotalAmount int       `json:"total_amount" example:"35000"`
-	Status      string    `json:"status" example:"completed"`
-	CreatedAt   time.Time `json:"created_at" example:"2026-02-10T10:00:00Z"`
+	ID            int       `json:"id" example:"1"`
+	StoreID       int       `json:"store_id" example:"1"`
+	TotalAmount   int       `json:"total_amount" example:"35000"`
+	PaymentMethod string    `json:"payment_method" example:"cash"`
+	PaidAmount    int       `json:"paid_amount" example:"50000"`
+	ChangeAmount  int       `json:"change_amount" example:"15000"`
+	Status        string    `json:"status" example:"completed"`
+	CreatedAt     time.Time `json:"created_at" example:"2026-02-10T10:00:00Z"`
 }
 
 type TransactionDetail struct {
@@ -18,15 +22,34 @@ type TransactionDetail struct {
 }
 
 type TransactionItem struct {
-	ProductID int `json:"product_id" example:"1"`
-	Quantity  int `json:"quantity" example:"2"`
+	ProductID int `json:"product_id" example:"1" validate:"required"`
+	Quantity  int `json:"quantity" example:"2" validate:"min=1"`
 }
 
 type TransactionRequest struct {
-	Items []TransactionItem `json:"items" example:"[{\"product_id\":1,\"quantity\":2}]"`
+	StoreID       int               `json:"store_id" example:"1" validate:"min=0"`
+	Items         []TransactionItem `json:"items" example:"[{\"product_id\":1,\"quantity\":2}]" validate:"required,max=500"`
+	PaymentMethod string            `json:"payment_method" example:"cash" validate:"max=20"`
+	PaidAmount    int               `json:"paid_amount" example:"50000" validate:"min=0"`
 }
 
 type TransactionWithDetails struct {
 	Transaction
 	Details []TransactionDetail `json:"details"`
 }
+
+// TransactionLine is one sold item with its transaction header, as exported
+// for spreadsheets.
+type TransactionLine struct {
+	TransactionID int       `json:"transaction_id" example:"1"`
+	CreatedAt     time.Time `json:"created_at" example:"2026-02-10T10:00:00Z"`
+	StoreID       int       `json:"store_id" example:"1"`
+	StoreName     string    `json:"store_name" example:"Outlet Pusat"`
+	PaymentMethod string    `json:"payment_method" example:"cash"`
+	ProductID     int       `json:"product_id" example:"1"`
+	ProductName   string    `json:"product_name" example:"Indomie Goreng"`
+	CategoryName  string    `json:"category_name" example:"Makanan"`
+	Quantity      int       `json:"quantity" example:"2"`
+	Price         int       `json:"price" example:"3500"`
+	Subtotal      int       `json:"subtotal" example:"7000"`
+}
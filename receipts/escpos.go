@@ -0,0 +1,32 @@
+package receipts
+
+import (
+	"bytes"
+	"io"
+)
+
+var (
+	escposInit = []byte{0x1B, 0x40}             // ESC @: reset printer
+	escposFeed = []byte{0x1B, 0x64, 0x04}       // ESC d 4: feed four lines
+	escposCut  = []byte{0x1D, 0x56, 0x42, 0x00} // GS V B 0: feed to cutter and partial cut
+)
+
+// writeESCPOS wraps the text receipt in the ESC/POS commands understood by
+// common thermal printers. Characters outside ASCII are replaced because the
+// printer code page is unknown.
+func writeESCPOS(w io.Writer, text string) error {
+	var buf bytes.Buffer
+	buf.Write(escposInit)
+	for _, r := range text {
+		if r == '\n' || (r >= 0x20 && r < 0x7F) {
+			buf.WriteRune(r)
+		} else {
+			buf.WriteByte('?')
+		}
+	}
+	buf.Write(escposFeed)
+	buf.Write(escposCut)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
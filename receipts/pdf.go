@@ -0,0 +1,72 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	pdfFontSize   = 9.0
+	pdfLeading    = 11.0
+	pdfCharWidth  = 5.4 // Courier glyphs are 600/1000 em wide
+	pdfPageMargin = 18.0
+)
+
+// writePDF renders monospaced lines onto a single page sized to fit the
+// receipt, using the built-in Courier font so no font embedding is needed.
+func writePDF(w io.Writer, lines []string, width int) error {
+	pageWidth := float64(width)*pdfCharWidth + 2*pdfPageMargin
+	pageHeight := float64(len(lines))*pdfLeading + 2*pdfPageMargin
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT\n/F1 %.0f Tf\n%.0f TL\n%.2f %.2f Td\n", pdfFontSize, pdfLeading, pdfPageMargin, pageHeight-pdfPageMargin-pdfFontSize)
+	for _, line := range lines {
+		content.WriteByte('(')
+		content.Write(pdfEscape(line))
+		content.WriteString(") Tj T*\n")
+	}
+	content.WriteString("ET\n")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// pdfEscape converts a line to Latin-1 and escapes PDF string delimiters.
+func pdfEscape(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out = append(out, '\\', byte(r))
+		case r < 256:
+			out = append(out, byte(r))
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
@@ -0,0 +1,236 @@
+package receipts
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"categories-api/models"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	FormatText   = "text"
+	FormatHTML   = "html"
+	FormatPDF    = "pdf"
+	FormatESCPOS = "escpos"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported receipt format, use text, html, pdf or escpos")
+
+const defaultWidth = 32
+
+const defaultTextTemplate = `{{center .StoreName}}
+{{if .StoreAddress}}{{center .StoreAddress}}
+{{end}}{{if .StorePhone}}{{center .StorePhone}}
+{{end}}{{divider}}
+{{justify "No" (printf "#%d" .TransactionID)}}
+{{justify "Tanggal" (.CreatedAt.Format "02/01/2006 15:04")}}
+{{divider}}
+{{range .Items}}{{.Name}}
+{{justify (printf "  %d x %s" .Quantity (rupiah .Price)) (rupiah .Subtotal)}}
+{{end}}{{divider}}
+{{justify "TOTAL" (rupiah .TotalAmount)}}
+{{justify (upper .PaymentMethod) (rupiah .PaidAmount)}}
+{{justify "KEMBALI" (rupiah .ChangeAmount)}}
+{{divider}}
+{{if .Footer}}{{center .Footer}}
+{{end}}`
+
+const defaultHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Struk #{{.TransactionID}}</title>
+<style>
+body { font-family: monospace; max-width: 360px; margin: 0 auto; }
+table { width: 100%; border-collapse: collapse; }
+td.right { text-align: right; }
+.center { text-align: center; }
+hr { border: 0; border-top: 1px dashed #000; }
+</style>
+</head>
+<body>
+<div class="center">
+<strong>{{.StoreName}}</strong><br>
+{{if .StoreAddress}}{{.StoreAddress}}<br>{{end}}
+{{if .StorePhone}}{{.StorePhone}}<br>{{end}}
+</div>
+<hr>
+<table>
+<tr><td>No</td><td class="right">#{{.TransactionID}}</td></tr>
+<tr><td>Tanggal</td><td class="right">{{.CreatedAt.Format "02/01/2006 15:04"}}</td></tr>
+</table>
+<hr>
+<table>
+{{range .Items}}<tr><td colspan="2">{{.Name}}</td></tr>
+<tr><td>&nbsp;&nbsp;{{.Quantity}} x {{rupiah .Price}}</td><td class="right">{{rupiah .Subtotal}}</td></tr>
+{{end}}</table>
+<hr>
+<table>
+<tr><td><strong>TOTAL</strong></td><td class="right"><strong>{{rupiah .TotalAmount}}</strong></td></tr>
+<tr><td>{{upper .PaymentMethod}}</td><td class="right">{{rupiah .PaidAmount}}</td></tr>
+<tr><td>KEMBALI</td><td class="right">{{rupiah .ChangeAmount}}</td></tr>
+</table>
+<hr>
+{{if .Footer}}<p class="center">{{.Footer}}</p>{{end}}
+</body>
+</html>
+`
+
+// ContentType returns the MIME type served for the given receipt format.
+func ContentType(format string) string {
+	switch format {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatPDF:
+		return "application/pdf"
+	case FormatESCPOS:
+		return "application/octet-stream"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// ApplyStoreInfo fills the parts of the store header that the store record
+// left empty from config, plus the footer.
+func ApplyStoreInfo(receipt *models.Receipt) {
+	if receipt.StoreName == "" {
+		receipt.StoreName = viper.GetString("STORE_NAME")
+	}
+	if receipt.StoreAddress == "" {
+		receipt.StoreAddress = viper.GetString("STORE_ADDRESS")
+	}
+	if receipt.StorePhone == "" {
+		receipt.StorePhone = viper.GetString("STORE_PHONE")
+	}
+	receipt.Footer = viper.GetString("RECEIPT_FOOTER")
+
+	if receipt.StoreName == "" {
+		receipt.StoreName = "Kasir"
+	}
+	if receipt.Footer == "" {
+		receipt.Footer = "Terima kasih"
+	}
+}
+
+// Render writes the receipt in the requested format. Text, PDF and ESC/POS
+// share the same text template so thermal prints and PDFs look identical.
+func Render(w io.Writer, format string, receipt *models.Receipt) error {
+	switch format {
+	case FormatText:
+		return renderText(w, receipt)
+	case FormatHTML:
+		return renderHTML(w, receipt)
+	case FormatPDF:
+		var buf bytes.Buffer
+		if err := renderText(&buf, receipt); err != nil {
+			return err
+		}
+		return writePDF(w, strings.Split(strings.TrimRight(buf.String(), "\n"), "\n"), width())
+	case FormatESCPOS:
+		var buf bytes.Buffer
+		if err := renderText(&buf, receipt); err != nil {
+			return err
+		}
+		return writeESCPOS(w, buf.String())
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+func renderText(w io.Writer, receipt *models.Receipt) error {
+	src, err := loadTemplate("RECEIPT_TEMPLATE", defaultTextTemplate)
+	if err != nil {
+		return err
+	}
+	tmpl, err := texttemplate.New("receipt").Funcs(textFuncs(width())).Parse(src)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, receipt)
+}
+
+func renderHTML(w io.Writer, receipt *models.Receipt) error {
+	src, err := loadTemplate("RECEIPT_HTML_TEMPLATE", defaultHTMLTemplate)
+	if err != nil {
+		return err
+	}
+	tmpl, err := htmltemplate.New("receipt").Funcs(htmltemplate.FuncMap{
+		"rupiah": Rupiah,
+		"upper":  strings.ToUpper,
+	}).Parse(src)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, receipt)
+}
+
+// loadTemplate reads the template file configured under key, falling back to
+// the built-in template when no path is set.
+func loadTemplate(key, fallback string) (string, error) {
+	path := viper.GetString(key)
+	if path == "" {
+		return fallback, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func width() int {
+	if w := viper.GetInt("RECEIPT_WIDTH"); w > 0 {
+		return w
+	}
+	return defaultWidth
+}
+
+func textFuncs(width int) texttemplate.FuncMap {
+	return texttemplate.FuncMap{
+		"rupiah": Rupiah,
+		"upper":  strings.ToUpper,
+		"divider": func() string {
+			return strings.Repeat("-", width)
+		},
+		"center": func(s string) string {
+			pad := (width - len([]rune(s))) / 2
+			if pad <= 0 {
+				return s
+			}
+			return strings.Repeat(" ", pad) + s
+		},
+		"justify": func(left, right string) string {
+			gap := width - len([]rune(left)) - len([]rune(right))
+			if gap < 1 {
+				gap = 1
+			}
+			return left + strings.Repeat(" ", gap) + right
+		},
+	}
+}
+
+// Rupiah formats an amount with dot thousand separators, e.g. 35000 -> "Rp35.000".
+func Rupiah(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.Itoa(amount)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return sign + "Rp" + b.String()
+}
@@ -0,0 +1,302 @@
+package repositories
+
+import (
+	"categories-api/database"
+	"categories-api/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// maxBulkItems caps the number of IDs in one bulk request.
+const maxBulkItems = 1000
+
+// BulkUpdateProducts applies one change to many products in a single
+// transaction. Every product is checked and reported; if any of them fails,
+// nothing is saved. A stock change is applied to the tenant's default store
+// like UpdateProduct does.
+func BulkUpdateProducts(ctx context.Context, tenantID int, update models.ProductBulkUpdate) (*models.BulkResult, error) {
+	if update.Price == nil && update.PriceChangePct == nil && update.CategoriesID == nil && update.Stock == nil {
+		return nil, &ValidationError{Message: "nothing to update, set price, price_change_pct, categories_id or stock"}
+	}
+	if update.Price != nil && update.PriceChangePct != nil {
+		return nil, &ValidationError{Message: "price and price_change_pct cannot be used together"}
+	}
+	if update.Price != nil && *update.Price <= 0 {
+		return nil, &ValidationError{Message: "price must be greater than 0"}
+	}
+	if update.PriceChangePct != nil && *update.PriceChangePct <= -100 {
+		return nil, &ValidationError{Message: "price_change_pct must be greater than -100"}
+	}
+	if update.Stock != nil && *update.Stock < 0 {
+		return nil, &ValidationError{Message: "stock must not be negative"}
+	}
+	if err := checkBulkIDs(update.IDs); err != nil {
+		return nil, err
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if update.CategoriesID != nil {
+		if err = categoryExists(ctx, tx, tenantID, *update.CategoriesID); err != nil {
+			return nil, err
+		}
+	}
+
+	storeID, err := defaultStoreID(ctx, tx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := applyBulk(update.IDs, func(id int, res *models.BulkItemResult) error {
+		var p models.Product
+		err := tx.QueryRowContext(ctx, "SELECT id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version FROM products WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL FOR UPDATE", id, tenantID).Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.Cost, &p.Stock, &p.CategoriesID, &p.Barcode, &p.Archived, &p.Version)
+		if err == sql.ErrNoRows {
+			res.Errors = append(res.Errors, "product not found")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		price := p.Price
+		if update.Price != nil {
+			price = *update.Price
+		}
+		if update.PriceChangePct != nil {
+			price = int(math.Round(float64(p.Price) * (100 + *update.PriceChangePct) / 100))
+			if price <= 0 {
+				res.Errors = append(res.Errors, fmt.Sprintf("new price %d must be greater than 0", price))
+			}
+		}
+
+		delta := 0
+		if update.Stock != nil {
+			delta = *update.Stock - p.Stock
+			shortfall, err := defaultStockShortfall(ctx, tx, storeID, id, delta)
+			if err != nil {
+				return err
+			}
+			if shortfall != "" {
+				res.Errors = append(res.Errors, shortfall)
+			}
+		}
+
+		if len(res.Errors) > 0 {
+			return nil
+		}
+
+		categoryID := p.CategoriesID
+		if update.CategoriesID != nil {
+			categoryID = *update.CategoriesID
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, price = $1, categories_id = $2 WHERE id = $3", price, categoryID, id)
+		if err != nil {
+			return err
+		}
+		if err = adjustStock(ctx, tx, storeID, id, delta); err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, "SELECT id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version FROM products WHERE id = $1", id).Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.Cost, &p.Stock, &p.CategoriesID, &p.Barcode, &p.Archived, &p.Version)
+		if err != nil {
+			return err
+		}
+		res.Action = "update"
+		res.Product = &p
+		return nil
+	})
+	if err != nil || result.Failed > 0 {
+		return result, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	refreshSuggestions(ctx, tenantID, update.IDs...)
+	return result, nil
+}
+
+// BulkDeleteProducts soft-deletes many products in a single transaction.
+// Products that were sold or transferred are kept for the history and fail
+// the request.
+func BulkDeleteProducts(ctx context.Context, tenantID int, ids []int) (*models.BulkResult, error) {
+	if err := checkBulkIDs(ids); err != nil {
+		return nil, err
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := applyBulk(ids, func(id int, res *models.BulkItemResult) error {
+		var exists, referenced bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS(SELECT 1 FROM products WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL),
+				EXISTS(SELECT 1 FROM transaction_details WHERE product_id = $1)
+				OR EXISTS(SELECT 1 FROM stock_transfer_items WHERE product_id = $1)
+		`, id, tenantID).Scan(&exists, &referenced)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			res.Errors = append(res.Errors, "product not found")
+			return nil
+		}
+		if referenced {
+			res.Errors = append(res.Errors, "product has transactions or stock transfers")
+			return nil
+		}
+
+		if _, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, deleted_at = (now() AT TIME ZONE 'UTC') WHERE id = $1", id); err != nil {
+			return err
+		}
+		res.Action = "delete"
+		return nil
+	})
+	if err != nil || result.Failed > 0 {
+		return result, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	refreshSuggestions(ctx, tenantID, ids...)
+	return result, nil
+}
+
+// BulkDeleteCategories soft-deletes many categories in a single transaction.
+// Their products are handled according to opts as in Delete, so a category
+// that still has products fails the request unless they are reassigned or
+// cascaded.
+func BulkDeleteCategories(ctx context.Context, tenantID int, ids []int, opts CategoryDeleteOptions) (*models.BulkResult, error) {
+	if err := checkBulkIDs(ids); err != nil {
+		return nil, err
+	}
+	if opts.Cascade && opts.ReassignTo > 0 {
+		return nil, &ValidationError{Message: "reassign_to and cascade cannot be combined"}
+	}
+	if slices.Contains(ids, opts.ReassignTo) {
+		return nil, &ValidationError{Message: "cannot reassign products to a category being deleted"}
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if opts.ReassignTo > 0 {
+		if err = categoryExists(ctx, tx, tenantID, opts.ReassignTo); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := applyBulk(ids, func(id int, res *models.BulkItemResult) error {
+		var (
+			notFound *NotFoundError
+			conflict *ConflictError
+		)
+		err := lockCategory(ctx, tx, tenantID, id, nil)
+		if err == nil {
+			_, err = deleteCategory(ctx, tx, tenantID, id, opts)
+		}
+		switch {
+		case errors.As(err, &notFound):
+			res.Errors = append(res.Errors, "category not found")
+			return nil
+		case errors.As(err, &conflict):
+			res.Errors = append(res.Errors, conflict.Message)
+			return nil
+		case err != nil:
+			return err
+		}
+		res.Action = "delete"
+		return nil
+	})
+	if err != nil || result.Failed > 0 {
+		return result, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	forgetSuggestions(tenantID)
+	return result, nil
+}
+
+func checkBulkIDs(ids []int) error {
+	if len(ids) == 0 {
+		return &ValidationError{Message: "ids is required"}
+	}
+	if len(ids) > maxBulkItems {
+		return &ValidationError{Message: fmt.Sprintf("at most %d ids per request", maxBulkItems)}
+	}
+	return nil
+}
+
+// applyBulk runs apply for every ID and collects the results. apply records
+// problems with one item on its result and returns an error only when the
+// whole request has to stop. When any item failed the caller rolls back, so
+// the actions and snapshots of the other items are cleared again.
+func applyBulk(ids []int, apply func(id int, res *models.BulkItemResult) error) (*models.BulkResult, error) {
+	result := &models.BulkResult{
+		Total:   len(ids),
+		Results: make([]models.BulkItemResult, 0, len(ids)),
+	}
+	seen := map[int]bool{}
+
+	for _, id := range ids {
+		res := models.BulkItemResult{ID: id}
+		if seen[id] {
+			res.Errors = append(res.Errors, "duplicate id")
+		} else {
+			seen[id] = true
+			if err := apply(id, &res); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(res.Errors) > 0 {
+			result.Failed++
+		} else {
+			result.Succeeded++
+		}
+		result.Results = append(result.Results, res)
+	}
+
+	if result.Failed > 0 {
+		for i := range result.Results {
+			result.Results[i].Action = ""
+			result.Results[i].Product = nil
+		}
+	}
+	return result, nil
+}
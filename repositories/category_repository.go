@@ -3,81 +3,324 @@ package repositories
 import (
 	"categories-api/database"
 	"categories-api/models"
+	"categories-api/validation"
+	"context"
+	"database/sql"
 )
 
 func InitDummyData() {
 }
 
-func GetAll() []models.Category {
-	db := database.GetDB()
-	rows, err := db.Query("SELECT id, name, description, created_at, updated_at FROM categories ORDER BY id")
+// GetAll lists the categories. Soft-deleted categories are left out unless
+// includeDeleted is set.
+func GetAll(ctx context.Context, tenantID int, includeDeleted bool) ([]models.Category, error) {
+	db, err := database.ForTenant(ctx, tenantID)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, name, description, created_at, updated_at, version, deleted_at FROM categories WHERE tenant_id = $1 AND ($2 OR deleted_at IS NULL) ORDER BY id", tenantID, includeDeleted)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
 	var categories []models.Category
 	for rows.Next() {
 		var c models.Category
-		err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt)
-		if err != nil {
-			continue
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt, &c.Version, &c.DeletedAt); err != nil {
+			return nil, err
 		}
 		categories = append(categories, c)
 	}
-	return categories
+	return categories, rows.Err()
 }
 
-func GetByName(name string) []models.Category {
-	db := database.GetDB()
-	rows, err := db.Query("SELECT id, name, description, created_at, updated_at FROM categories WHERE name ILIKE $1 ORDER BY id", "%"+name+"%")
+func GetByName(ctx context.Context, tenantID int, name string) ([]models.Category, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, name, description, created_at, updated_at, version FROM categories WHERE tenant_id = $1 AND deleted_at IS NULL AND name ILIKE $2 ORDER BY id", tenantID, "%"+name+"%")
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer rows.Close()
 
 	var categories []models.Category
 	for rows.Next() {
 		var c models.Category
-		err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt)
-		if err != nil {
-			continue
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt, &c.Version); err != nil {
+			return nil, err
 		}
 		categories = append(categories, c)
 	}
-	return categories
+	return categories, rows.Err()
 }
 
-func GetByID(id int) (*models.Category, error) {
-	db := database.GetDB()
-	var c models.Category
-	err := db.QueryRow("SELECT id, name, description, created_at, updated_at FROM categories WHERE id = $1", id).Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt)
+func GetByID(ctx context.Context, tenantID, id int) (*models.Category, error) {
+	db, err := database.ForTenant(ctx, tenantID)
 	if err != nil {
 		return nil, err
 	}
+	defer db.Close()
+
+	var c models.Category
+	err = db.QueryRowContext(ctx, "SELECT id, name, description, created_at, updated_at, version FROM categories WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL", id, tenantID).Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt, &c.Version)
+	if err != nil {
+		return nil, notFound(err, "category", id)
+	}
 	return &c, nil
 }
 
-func Create(category models.Category) (*models.Category, error) {
-	db := database.GetDB()
-	err := db.QueryRow("INSERT INTO categories (name, description, updated_at) VALUES ($1, $2, CURRENT_TIMESTAMP) RETURNING id, name, description, created_at, updated_at", category.Name, category.Description).Scan(&category.ID, &category.Name, &category.Description, &category.CreatedAt, &category.UpdatedAt)
+func Create(ctx context.Context, tenantID int, category models.Category) (*models.Category, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	err = db.QueryRowContext(ctx, "INSERT INTO categories (tenant_id, name, description, updated_at) VALUES ($1, $2, $3, (now() AT TIME ZONE 'UTC')) RETURNING id, name, description, created_at, updated_at, version", tenantID, category.Name, category.Description).Scan(&category.ID, &category.Name, &category.Description, &category.CreatedAt, &category.UpdatedAt, &category.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Update overwrites the category when its version satisfies pre.
+func Update(ctx context.Context, tenantID, id int, category models.Category, pre Precondition) (*models.Category, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = lockCategory(ctx, tx, tenantID, id, pre); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, "UPDATE categories SET version = version + 1, name = $1, description = $2, updated_at = (now() AT TIME ZONE 'UTC') WHERE id = $3 RETURNING id, name, description, created_at, updated_at, version", category.Name, category.Description, id).Scan(&category.ID, &category.Name, &category.Description, &category.CreatedAt, &category.UpdatedAt, &category.Version)
 	if err != nil {
 		return nil, err
 	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
 	return &category, nil
 }
 
-func Update(id int, category models.Category) (*models.Category, error) {
-	db := database.GetDB()
-	err := db.QueryRow("UPDATE categories SET name = $1, description = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3 RETURNING id, name, description, created_at, updated_at", category.Name, category.Description, id).Scan(&category.ID, &category.Name, &category.Description, &category.CreatedAt, &category.UpdatedAt)
+// PatchCategory applies a merge patch to the category when its version
+// satisfies pre. The patched category is validated as a whole, but only the
+// fields in the patch are written.
+func PatchCategory(ctx context.Context, tenantID, id int, patch validation.Patch, pre Precondition) (*models.Category, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var category models.Category
+	err = tx.QueryRowContext(ctx, "SELECT id, name, description, created_at, updated_at, version FROM categories WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL FOR UPDATE", id, tenantID).Scan(&category.ID, &category.Name, &category.Description, &category.CreatedAt, &category.UpdatedAt, &category.Version)
 	if err != nil {
+		return nil, notFound(err, "category", id)
+	}
+	if err = pre.check("category", id, category.Version); err != nil {
+		return nil, err
+	}
+
+	if err = patch.Apply(&category); err != nil {
+		return nil, err
+	}
+	if err = patch.Restrict("name", "description"); err != nil {
+		return nil, err
+	}
+
+	columns := newPatchColumns(patch, "version = version + 1", "updated_at = (now() AT TIME ZONE 'UTC')")
+	columns.set("name", "name = $%d", category.Name)
+	columns.set("description", "description = $%d", category.Description)
+	if err = columns.exec(ctx, tx, "categories", id); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT id, name, description, created_at, updated_at, version FROM categories WHERE id = $1", id).Scan(&category.ID, &category.Name, &category.Description, &category.CreatedAt, &category.UpdatedAt, &category.Version)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 	return &category, nil
 }
 
-func Delete(id int) error {
-	db := database.GetDB()
-	_, err := db.Exec("DELETE FROM categories WHERE id = $1", id)
-	return err
+// lockCategory locks a category that is not deleted for the rest of the
+// transaction and checks its version against pre.
+func lockCategory(ctx context.Context, tx *sql.Tx, tenantID, id int, pre Precondition) error {
+	var version int
+	err := tx.QueryRowContext(ctx, "SELECT version FROM categories WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL FOR UPDATE", id, tenantID).Scan(&version)
+	if err != nil {
+		return notFound(err, "category", id)
+	}
+	return pre.check("category", id, version)
+}
+
+// CategoryDeleteOptions chooses what happens to the products of a category
+// being deleted. Without either option a category that still has products
+// is not deleted.
+type CategoryDeleteOptions struct {
+	// ReassignTo moves the products to this category first.
+	ReassignTo int
+	// Cascade deletes the products too; restoring the category brings them
+	// back. Products with sales or transfer history cannot be deleted, so
+	// they have to be reassigned instead.
+	Cascade bool
+}
+
+// Delete soft-deletes a category according to opts when its version
+// satisfies pre; Restore brings it back until PurgeDeleted removes it. When
+// its products are in the way it returns a ConflictError counting them.
+func Delete(ctx context.Context, tenantID, id int, opts CategoryDeleteOptions, pre Precondition) error {
+	if opts.Cascade && opts.ReassignTo > 0 {
+		return &ValidationError{Message: "reassign_to and cascade cannot be combined"}
+	}
+	if opts.ReassignTo == id {
+		return &ValidationError{Message: "cannot reassign products to the category being deleted"}
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = lockCategory(ctx, tx, tenantID, id, pre); err != nil {
+		return err
+	}
+	products, err := deleteCategory(ctx, tx, tenantID, id, opts)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	if products > 0 {
+		forgetSuggestions(tenantID)
+	}
+	return nil
+}
+
+// deleteCategory soft-deletes a category locked by tx, moving or deleting
+// its products according to opts, and returns how many products it
+// touched. A ConflictError or ValidationError leaves the category as it is.
+func deleteCategory(ctx context.Context, tx *sql.Tx, tenantID, id int, opts CategoryDeleteOptions) (int, error) {
+	var products, withHistory int
+	err := tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM products WHERE categories_id = $1 AND deleted_at IS NULL),
+			(SELECT COUNT(*) FROM products p WHERE p.categories_id = $1 AND p.deleted_at IS NULL AND (
+				EXISTS(SELECT 1 FROM transaction_details WHERE product_id = p.id)
+				OR EXISTS(SELECT 1 FROM stock_transfer_items WHERE product_id = p.id)))
+	`, id).Scan(&products, &withHistory)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case products == 0:
+	case opts.ReassignTo > 0:
+		if err = categoryExists(ctx, tx, tenantID, opts.ReassignTo); err != nil {
+			return 0, err
+		}
+		if _, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, categories_id = $1 WHERE categories_id = $2", opts.ReassignTo, id); err != nil {
+			return 0, err
+		}
+	case opts.Cascade:
+		if withHistory > 0 {
+			return 0, &ConflictError{
+				Message: "category has products with sales or transfer history; reassign them with reassign_to",
+				Details: map[string]int{"products": products, "products_with_history": withHistory},
+			}
+		}
+		// now() is fixed for the transaction, so the products share the
+		// category's deleted_at and Restore can find them.
+		if _, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, deleted_at = (now() AT TIME ZONE 'UTC') WHERE categories_id = $1 AND deleted_at IS NULL", id); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, &ConflictError{
+			Message: "category has products; delete them with cascade=true or move them with reassign_to",
+			Details: map[string]int{"products": products},
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE categories SET version = version + 1, deleted_at = (now() AT TIME ZONE 'UTC') WHERE id = $1", id); err != nil {
+		return 0, err
+	}
+	return products, nil
+}
+
+// Restore undoes Delete, together with the products deleted by the same
+// cascade. Products deleted on their own before stay deleted.
+func Restore(ctx context.Context, tenantID, id int) (*models.Category, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deleted bool
+	err = tx.QueryRowContext(ctx, "SELECT deleted_at IS NOT NULL FROM categories WHERE id = $1 AND tenant_id = $2 FOR UPDATE", id, tenantID).Scan(&deleted)
+	if err != nil {
+		return nil, notFound(err, "category", id)
+	}
+	if !deleted {
+		return nil, &ConflictError{Message: "category is not deleted"}
+	}
+
+	result, err := tx.ExecContext(ctx, "UPDATE products SET version = version + 1, deleted_at = NULL WHERE categories_id = $1 AND deleted_at = (SELECT deleted_at FROM categories WHERE id = $1)", id)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	var c models.Category
+	err = tx.QueryRowContext(ctx, "UPDATE categories SET version = version + 1, deleted_at = NULL WHERE id = $1 RETURNING id, name, description, created_at, updated_at, version", id).Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt, &c.Version)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	if restored > 0 {
+		forgetSuggestions(tenantID)
+	}
+	return &c, nil
 }
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ValidationError reports a request the API cannot act on as sent: a missing
+// or malformed value, or a reference to a record that does not exist.
+type ValidationError struct {
+	Message   string
+	ProductID int
+}
+
+func (e *ValidationError) Error() string {
+	if e.ProductID > 0 {
+		return fmt.Sprintf("%s (product_id: %d)", e.Message, e.ProductID)
+	}
+	return e.Message
+}
+
+// NotFoundError reports that the record addressed by the request does not
+// exist for the tenant.
+type NotFoundError struct {
+	Resource string
+	ID       int
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %d not found", e.Resource, e.ID)
+}
+
+// ConflictError reports a request that clashes with the current state, such
+// as a duplicate SKU or a transfer that was already received. Details counts
+// the records in the way, e.g. the products of a category being deleted.
+type ConflictError struct {
+	Message string
+	Details map[string]int
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// InsufficientStockError reports a sale or transfer of more units than the
+// store holds.
+type InsufficientStockError struct {
+	ProductID int
+	Requested int
+	Available int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product %d: requested %d, available %d", e.ProductID, e.Requested, e.Available)
+}
+
+// notFound turns sql.ErrNoRows from a lookup by ID into a NotFoundError and
+// passes other errors through.
+func notFound(err error, resource string, id int) error {
+	if err == sql.ErrNoRows {
+		return &NotFoundError{Resource: resource, ID: id}
+	}
+	return err
+}
+
+// deleted reports a NotFoundError when a delete by ID matched no row.
+func deleted(result sql.Result, resource string, id int) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &NotFoundError{Resource: resource, ID: id}
+	}
+	return nil
+}
+
+// PreconditionFailedError reports a write guarded by If-Match whose record
+// was changed by someone else since the client read it. Version is the
+// current one.
+type PreconditionFailedError struct {
+	Resource string
+	ID       int
+	Version  int
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("%s %d has been modified (current version %d)", e.Resource, e.ID, e.Version)
+}
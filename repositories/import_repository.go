@@ -0,0 +1,212 @@
+package repositories
+
+import (
+	"categories-api/database"
+	"categories-api/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ImportOptions controls ImportProducts. With DryRun every row is checked and
+// reported but nothing is saved. With CreateCategories unknown category names
+// are created instead of failing the row.
+type ImportOptions struct {
+	DryRun           bool
+	CreateCategories bool
+}
+
+// ImportProducts creates or updates products by SKU in a single transaction.
+// Every row is validated and reported; if any row fails, or on a dry run,
+// the transaction is rolled back and nothing is saved. A row without stock
+// keeps the current stock of an existing product; a stock change is applied
+// to the tenant's default store like UpdateProduct does.
+func ImportProducts(ctx context.Context, tenantID int, rows []models.ProductImportRow, opts ImportOptions) (*models.ProductImportResult, error) {
+	if len(rows) == 0 {
+		return nil, &ValidationError{Message: "no products to import"}
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	storeID, err := defaultStoreID(ctx, tx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := categoryIDsByName(ctx, tx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ProductImportResult{
+		DryRun:            opts.DryRun,
+		Total:             len(rows),
+		CategoriesCreated: []string{},
+		Rows:              make([]models.ProductImportRowResult, 0, len(rows)),
+	}
+	seen := map[string]int{}
+
+	for _, row := range rows {
+		row.SKU = strings.TrimSpace(row.SKU)
+		row.Name = strings.TrimSpace(row.Name)
+		row.Category = strings.TrimSpace(row.Category)
+
+		res := models.ProductImportRowResult{Row: row.Row, SKU: row.SKU}
+		res.Errors = append(res.Errors, validateImportRow(row)...)
+
+		if row.SKU != "" {
+			if first, ok := seen[row.SKU]; ok {
+				res.Errors = append(res.Errors, fmt.Sprintf("sku %s already appears in row %d", row.SKU, first))
+			} else {
+				seen[row.SKU] = row.Row
+			}
+		}
+
+		categoryID, found := categories[strings.ToLower(row.Category)]
+		if row.Category != "" && !found {
+			if opts.CreateCategories && len(res.Errors) == 0 {
+				err = tx.QueryRowContext(ctx, "INSERT INTO categories (tenant_id, name, updated_at) VALUES ($1, $2, (now() AT TIME ZONE 'UTC')) RETURNING id", tenantID, row.Category).Scan(&categoryID)
+				if err != nil {
+					return nil, err
+				}
+				categories[strings.ToLower(row.Category)] = categoryID
+				result.CategoriesCreated = append(result.CategoriesCreated, row.Category)
+			} else if !opts.CreateCategories {
+				res.Errors = append(res.Errors, fmt.Sprintf("category %q not found", row.Category))
+			}
+		}
+
+		if len(res.Errors) > 0 {
+			result.Failed++
+			result.Rows = append(result.Rows, res)
+			continue
+		}
+
+		var currentStock int
+		err = tx.QueryRowContext(ctx, "SELECT id, stock FROM products WHERE tenant_id = $1 AND sku = $2 FOR UPDATE", tenantID, row.SKU).Scan(&res.ProductID, &currentStock)
+		switch {
+		case err == sql.ErrNoRows:
+			stock := 0
+			if row.Stock != nil {
+				stock = *row.Stock
+			}
+			err = tx.QueryRowContext(ctx, "INSERT INTO products (tenant_id, sku, name, price, cost, stock, categories_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id", tenantID, row.SKU, row.Name, row.Price, row.Cost, stock, categoryID).Scan(&res.ProductID)
+			if err != nil {
+				return nil, err
+			}
+			_, err = tx.ExecContext(ctx, "INSERT INTO store_stocks (store_id, product_id, stock) VALUES ($1, $2, $3)", storeID, res.ProductID, stock)
+			if err != nil {
+				return nil, err
+			}
+			if err = recordStockReceipt(ctx, tx, storeID, res.ProductID, stock); err != nil {
+				return nil, err
+			}
+			res.Action = "create"
+			result.Created++
+
+		case err == nil:
+			if row.Stock != nil {
+				shortfall, err := defaultStockShortfall(ctx, tx, storeID, res.ProductID, *row.Stock-currentStock)
+				if err != nil {
+					return nil, err
+				}
+				if shortfall != "" {
+					res.Errors = append(res.Errors, shortfall)
+					result.Failed++
+					break
+				}
+			}
+			_, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, name = $1, price = $2, cost = $3, categories_id = $4, deleted_at = NULL WHERE id = $5", row.Name, row.Price, row.Cost, categoryID, res.ProductID)
+			if err != nil {
+				return nil, err
+			}
+			if row.Stock != nil {
+				if err = adjustStock(ctx, tx, storeID, res.ProductID, *row.Stock-currentStock); err != nil {
+					return nil, err
+				}
+			}
+			res.Action = "update"
+			result.Updated++
+
+		default:
+			return nil, err
+		}
+
+		result.Rows = append(result.Rows, res)
+	}
+
+	if result.Failed > 0 || opts.DryRun {
+		return result, nil
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]int, len(result.Rows))
+	for i, row := range result.Rows {
+		productIDs[i] = row.ProductID
+	}
+	refreshSuggestions(ctx, tenantID, productIDs...)
+	return result, nil
+}
+
+// validateImportRow checks the fields of a row that do not need the database.
+func validateImportRow(row models.ProductImportRow) []string {
+	errs := append([]string(nil), row.Errors...)
+	if row.SKU == "" {
+		errs = append(errs, "sku is required")
+	} else if len(row.SKU) > 64 {
+		errs = append(errs, "sku must be at most 64 characters")
+	}
+	if row.Name == "" {
+		errs = append(errs, "name is required")
+	}
+	if row.Price <= 0 {
+		errs = append(errs, "price must be greater than 0")
+	}
+	if row.Cost < 0 {
+		errs = append(errs, "cost must not be negative")
+	}
+	if row.Stock != nil && *row.Stock < 0 {
+		errs = append(errs, "stock must not be negative")
+	}
+	if row.Category == "" {
+		errs = append(errs, "category is required")
+	}
+	return errs
+}
+
+// categoryIDsByName maps the lower-cased category names of the tenant to
+// their ID. When two categories share a name the oldest one wins.
+func categoryIDsByName(ctx context.Context, tx *sql.Tx, tenantID int) (map[string]int, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, name FROM categories WHERE tenant_id = $1 AND deleted_at IS NULL ORDER BY id", tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[string]int{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(strings.TrimSpace(name))
+		if _, ok := ids[key]; !ok {
+			ids[key] = id
+		}
+	}
+	return ids, rows.Err()
+}
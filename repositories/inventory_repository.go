@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"categories-api/database"
+	"categories-api/models"
+	"context"
+	"sort"
+	"time"
+)
+
+// GetInventoryReport values the stock on hand at cost and at selling price,
+// per product and per category, and splits it into age buckets. Aging assumes
+// stock leaves a store oldest first, so what is left is matched against the
+// newest receipts of that store. opts.StoreID limits the report to one store;
+// 0 covers all stores (stock in transit is not counted).
+func GetInventoryReport(ctx context.Context, tenantID int, opts ReportOptions) (*models.InventoryReport, error) {
+	loc, err := reportLocation(ctx, tenantID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	aging, err := getStockAging(ctx, db, tenantID, opts.StoreID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, p.name, p.categories_id, COALESCE(c.name, ''), p.cost, p.price,
+			CASE WHEN $2 = 0 THEN p.stock ELSE COALESCE(ss.stock, 0) END AS on_hand
+		FROM products p
+		LEFT JOIN categories c ON p.categories_id = c.id
+		LEFT JOIN store_stocks ss ON ss.product_id = p.id AND ss.store_id = $2
+		WHERE p.tenant_id = $1 AND p.deleted_at IS NULL
+		ORDER BY p.id
+	`, tenantID, opts.StoreID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &models.InventoryReport{
+		StoreID:     opts.StoreID,
+		GeneratedAt: time.Now().In(loc),
+	}
+	categories := map[int]*models.CategoryValuation{}
+
+	for rows.Next() {
+		var p models.ProductValuation
+		var categoryName string
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.CategoryID, &categoryName, &p.Cost, &p.Price, &p.Stock); err != nil {
+			return nil, err
+		}
+		if p.Stock <= 0 {
+			continue
+		}
+		p.CostValue = p.Stock * p.Cost
+		p.RetailValue = p.Stock * p.Price
+		p.Aging = aging[p.ProductID]
+		report.Products = append(report.Products, p)
+
+		c, ok := categories[p.CategoryID]
+		if !ok {
+			c = &models.CategoryValuation{CategoryID: p.CategoryID, Name: categoryName}
+			categories[p.CategoryID] = c
+		}
+		c.Stock += p.Stock
+		c.CostValue += p.CostValue
+		c.RetailValue += p.RetailValue
+		addAging(&c.Aging, p.Aging)
+
+		report.TotalStock += p.Stock
+		report.TotalCostValue += p.CostValue
+		report.TotalRetailValue += p.RetailValue
+		addAging(&report.Aging, p.Aging)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range categories {
+		report.Categories = append(report.Categories, *c)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		return report.Categories[i].CategoryID < report.Categories[j].CategoryID
+	})
+
+	return report, nil
+}
+
+// getStockAging returns the age buckets of the stock on hand per product,
+// summed over the stores in scope.
+func getStockAging(ctx context.Context, db *database.TenantDB, tenantID, storeID int) (map[int]models.StockAging, error) {
+	rows, err := db.QueryContext(ctx, `
+		WITH lots AS (
+			SELECT r.store_id, r.product_id, r.quantity, r.received_at,
+				SUM(r.quantity) OVER (PARTITION BY r.store_id, r.product_id ORDER BY r.received_at DESC, r.id DESC) AS received_since
+			FROM stock_receipts r
+			JOIN stores s ON r.store_id = s.id
+			WHERE s.tenant_id = $1 AND ($2 = 0 OR r.store_id = $2)
+		), remaining AS (
+			SELECT l.product_id,
+				date_part('day', (now() AT TIME ZONE 'UTC') - l.received_at) AS age_days,
+				LEAST(l.quantity, ss.stock - (l.received_since - l.quantity)) AS quantity
+			FROM lots l
+			JOIN store_stocks ss ON ss.store_id = l.store_id AND ss.product_id = l.product_id
+			WHERE l.received_since - l.quantity < ss.stock
+		)
+		SELECT product_id,
+			COALESCE(SUM(quantity) FILTER (WHERE age_days <= 30), 0),
+			COALESCE(SUM(quantity) FILTER (WHERE age_days > 30 AND age_days <= 60), 0),
+			COALESCE(SUM(quantity) FILTER (WHERE age_days > 60 AND age_days <= 90), 0),
+			COALESCE(SUM(quantity) FILTER (WHERE age_days > 90), 0)
+		FROM remaining
+		GROUP BY product_id
+	`, tenantID, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aging := map[int]models.StockAging{}
+	for rows.Next() {
+		var productID int
+		var a models.StockAging
+		if err := rows.Scan(&productID, &a.Days0To30, &a.Days31To60, &a.Days61To90, &a.Over90); err != nil {
+			return nil, err
+		}
+		aging[productID] = a
+	}
+	return aging, rows.Err()
+}
+
+func addAging(total *models.StockAging, a models.StockAging) {
+	total.Days0To30 += a.Days0To30
+	total.Days31To60 += a.Days31To60
+	total.Days61To90 += a.Days61To90
+	total.Over90 += a.Over90
+}
@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"categories-api/validation"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// patchColumns builds the UPDATE of a merge patch, so that only the fields
+// the client sent are written and concurrent changes to the others survive.
+type patchColumns struct {
+	patch validation.Patch
+	fixed []string
+	sets  []string
+	args  []any
+}
+
+// newPatchColumns starts an UPDATE for patch. fixed are assignments made
+// whenever a column changes, such as the version bump.
+func newPatchColumns(patch validation.Patch, fixed ...string) *patchColumns {
+	return &patchColumns{patch: patch, fixed: fixed}
+}
+
+// set assigns value to a column when the patch has field. expr uses %d for
+// the placeholder number, e.g. "name = $%d".
+func (c *patchColumns) set(field, expr string, value any) {
+	if !c.patch.Has(field) {
+		return
+	}
+	c.args = append(c.args, value)
+	c.sets = append(c.sets, fmt.Sprintf(expr, len(c.args)))
+}
+
+// exec updates the row with the given id. It does nothing when the patch
+// set no column.
+func (c *patchColumns) exec(ctx context.Context, tx *sql.Tx, table string, id int) error {
+	if len(c.sets) == 0 {
+		return nil
+	}
+	sets := append(c.fixed, c.sets...)
+	args := append(c.args, id)
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d", table, strings.Join(sets, ", "), len(args)), args...)
+	return err
+}
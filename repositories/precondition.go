@@ -0,0 +1,14 @@
+package repositories
+
+// Precondition guards an update or delete with the versions the client last
+// saw, taken from If-Match. A nil Precondition accepts any version.
+type Precondition func(version int) bool
+
+// check returns a PreconditionFailedError when the record's current version
+// does not satisfy p.
+func (p Precondition) check(resource string, id, version int) error {
+	if p == nil || p(version) {
+		return nil
+	}
+	return &PreconditionFailedError{Resource: resource, ID: id, Version: version}
+}
@@ -3,99 +3,581 @@ package repositories
 import (
 	"categories-api/database"
 	"categories-api/models"
+	"categories-api/validation"
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
 )
 
-func GetAllProducts() []models.Product {
-	db := database.GetDB()
-	rows, err := db.Query("SELECT id, name, price, stock, categories_id FROM products ORDER BY id")
+// GetAllProducts lists the catalog. Soft-deleted products are left out
+// unless includeDeleted is set.
+func GetAllProducts(ctx context.Context, tenantID int, includeDeleted bool) ([]models.Product, error) {
+	db, err := database.ForTenant(ctx, tenantID)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version, deleted_at FROM products WHERE tenant_id = $1 AND archived_at IS NULL AND ($2 OR deleted_at IS NULL) ORDER BY id", tenantID, includeDeleted)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
 	var products []models.Product
 	for rows.Next() {
 		var p models.Product
-		err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoriesID)
-		if err != nil {
-			continue
+		if err := rows.Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.Cost, &p.Stock, &p.CategoriesID, &p.Barcode, &p.Archived, &p.Version, &p.DeletedAt); err != nil {
+			return nil, err
 		}
 		products = append(products, p)
 	}
-	return products
+	return products, rows.Err()
 }
 
-func GetProductsByName(name string) []models.Product {
-	db := database.GetDB()
+func GetProductsByName(ctx context.Context, tenantID int, name string) ([]models.Product, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
 	searchPattern := "%" + name + "%"
-	rows, err := db.Query("SELECT id, name, price, stock, categories_id FROM products WHERE name ILIKE $1 ORDER BY id", searchPattern)
+	rows, err := db.QueryContext(ctx, "SELECT id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version FROM products WHERE tenant_id = $1 AND archived_at IS NULL AND deleted_at IS NULL AND name ILIKE $2 ORDER BY id", tenantID, searchPattern)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer rows.Close()
 
 	var products []models.Product
 	for rows.Next() {
 		var p models.Product
-		err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoriesID)
-		if err != nil {
-			continue
+		if err := rows.Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.Cost, &p.Stock, &p.CategoriesID, &p.Barcode, &p.Archived, &p.Version); err != nil {
+			return nil, err
 		}
 		products = append(products, p)
 	}
-	return products
+	return products, rows.Err()
 }
 
-func GetProductByID(id int) (*models.Product, error) {
-	db := database.GetDB()
-	var p models.Product
-	err := db.QueryRow("SELECT id, name, price, stock, categories_id FROM products WHERE id = $1", id).Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoriesID)
+// SearchProducts finds products whose name, SKU or category matches the
+// query, best match first. Every word of the query also matches as a prefix
+// ("indo gor" finds "Indomie Goreng"), and trigram similarity catches typos
+// like "indomi".
+func SearchProducts(ctx context.Context, tenantID int, query string) ([]models.Product, error) {
+	prefixQuery := prefixTSQuery(query)
+	if prefixQuery == "" {
+		return nil, nil
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, COALESCE(p.sku, ''), p.name, p.price, p.cost, p.stock, p.categories_id, COALESCE(p.barcode, ''), p.archived_at IS NOT NULL, p.version
+		FROM products p
+		JOIN categories c ON c.id = p.categories_id,
+			to_tsquery('simple', $2) AS q
+		WHERE p.tenant_id = $1 AND p.archived_at IS NULL AND p.deleted_at IS NULL AND (
+			to_tsvector('simple', p.name || ' ' || COALESCE(p.sku, '')) @@ q
+			OR to_tsvector('simple', c.name) @@ q
+			OR $3 <% p.name
+			OR $3 <% p.sku
+		)
+		ORDER BY
+			(LOWER(p.sku) = LOWER($3))::int * 2
+			+ ts_rank(to_tsvector('simple', p.name || ' ' || COALESCE(p.sku, '')), q) * 2
+			+ word_similarity($3, p.name)
+			+ COALESCE(word_similarity($3, p.sku), 0)
+			+ ts_rank(to_tsvector('simple', c.name), q) * 0.5 DESC,
+			p.name, p.id
+	`, tenantID, prefixQuery, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var p models.Product
+		if err := rows.Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.Cost, &p.Stock, &p.CategoriesID, &p.Barcode, &p.Archived, &p.Version); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// prefixTSQuery turns free text into a tsquery matching every word as a
+// prefix, e.g. "Indo gor" becomes "indo:* & gor:*". Anything but letters and
+// digits separates words, so user input cannot inject tsquery operators.
+func prefixTSQuery(query string) string {
+	words := searchWords(query)
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// searchWords splits text into lower-cased words of letters and digits.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func GetProductByID(ctx context.Context, tenantID, id int) (*models.Product, error) {
+	db, err := database.ForTenant(ctx, tenantID)
 	if err != nil {
 		return nil, err
 	}
+	defer db.Close()
+
+	var p models.Product
+	err = db.QueryRowContext(ctx, "SELECT id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version FROM products WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL", id, tenantID).Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.Cost, &p.Stock, &p.CategoriesID, &p.Barcode, &p.Archived, &p.Version)
+	if err != nil {
+		return nil, notFound(err, "product", id)
+	}
 	return &p, nil
 }
 
-func GetProductsByCategoryID(categoryID int) []models.Product {
-	db := database.GetDB()
-	rows, err := db.Query("SELECT id, name, price, stock, categories_id FROM products WHERE categories_id = $1 ORDER BY id", categoryID)
+func GetProductsByCategoryID(ctx context.Context, tenantID, categoryID int) ([]models.Product, error) {
+	db, err := database.ForTenant(ctx, tenantID)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version FROM products WHERE tenant_id = $1 AND categories_id = $2 AND archived_at IS NULL AND deleted_at IS NULL ORDER BY id", tenantID, categoryID)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
 	var products []models.Product
 	for rows.Next() {
 		var p models.Product
-		err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoriesID)
-		if err != nil {
-			continue
+		if err := rows.Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.Cost, &p.Stock, &p.CategoriesID, &p.Barcode, &p.Archived, &p.Version); err != nil {
+			return nil, err
 		}
 		products = append(products, p)
 	}
-	return products
+	return products, rows.Err()
 }
 
-func CreateProduct(product models.Product) (*models.Product, error) {
-	db := database.GetDB()
-	err := db.QueryRow("INSERT INTO products (name, price, stock, categories_id) VALUES ($1, $2, $3, $4) RETURNING id, name, price, stock, categories_id", product.Name, product.Price, product.Stock, product.CategoriesID).Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &product.CategoriesID)
+// CreateProduct stores the product and books its initial stock into the
+// tenant's default store.
+func CreateProduct(ctx context.Context, tenantID int, product models.Product) (*models.Product, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = categoryExists(ctx, tx, tenantID, product.CategoriesID); err != nil {
+		return nil, err
+	}
+
+	storeID, err := defaultStoreID(ctx, tx, tenantID)
 	if err != nil {
 		return nil, err
 	}
+
+	if err = skuAvailable(ctx, tx, tenantID, product.SKU, 0); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, "INSERT INTO products (tenant_id, sku, name, price, cost, stock, categories_id, barcode) VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, NULLIF($8, '')) RETURNING id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version", tenantID, product.SKU, product.Name, product.Price, product.Cost, product.Stock, product.CategoriesID, product.Barcode).Scan(&product.ID, &product.SKU, &product.Name, &product.Price, &product.Cost, &product.Stock, &product.CategoriesID, &product.Barcode, &product.Archived, &product.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO store_stocks (store_id, product_id, stock) VALUES ($1, $2, $3)", storeID, product.ID, product.Stock)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = recordStockReceipt(ctx, tx, storeID, product.ID, product.Stock); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	refreshSuggestions(ctx, tenantID, product.ID)
 	return &product, nil
 }
 
-func UpdateProduct(id int, product models.Product) (*models.Product, error) {
-	db := database.GetDB()
-	err := db.QueryRow("UPDATE products SET name = $1, price = $2, stock = $3, categories_id = $4 WHERE id = $5 RETURNING id, name, price, stock, categories_id", product.Name, product.Price, product.Stock, product.CategoriesID, id).Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &product.CategoriesID)
+// UpdateProduct overwrites the product when its version satisfies pre. A
+// change in stock is applied to the tenant's default store; other stores are
+// managed through SetStoreStock.
+func UpdateProduct(ctx context.Context, tenantID, id int, product models.Product, pre Precondition) (*models.Product, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var currentStock, version int
+	err = tx.QueryRowContext(ctx, "SELECT stock, version FROM products WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL FOR UPDATE", id, tenantID).Scan(&currentStock, &version)
 	if err != nil {
+		return nil, notFound(err, "product", id)
+	}
+	if err = pre.check("product", id, version); err != nil {
+		return nil, err
+	}
+
+	if err = categoryExists(ctx, tx, tenantID, product.CategoriesID); err != nil {
+		return nil, err
+	}
+
+	if err = skuAvailable(ctx, tx, tenantID, product.SKU, id); err != nil {
 		return nil, err
 	}
+
+	storeID, err := defaultStoreID(ctx, tx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	shortfall, err := defaultStockShortfall(ctx, tx, storeID, id, product.Stock-currentStock)
+	if err != nil {
+		return nil, err
+	}
+	if shortfall != "" {
+		return nil, &ValidationError{Message: shortfall}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, sku = NULLIF($1, ''), name = $2, price = $3, cost = $4, categories_id = $5, barcode = NULLIF($6, '') WHERE id = $7", product.SKU, product.Name, product.Price, product.Cost, product.CategoriesID, product.Barcode, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = adjustStock(ctx, tx, storeID, id, product.Stock-currentStock)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version FROM products WHERE id = $1", id).Scan(&product.ID, &product.SKU, &product.Name, &product.Price, &product.Cost, &product.Stock, &product.CategoriesID, &product.Barcode, &product.Archived, &product.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	refreshSuggestions(ctx, tenantID, id)
 	return &product, nil
 }
 
-func DeleteProduct(id int) error {
-	db := database.GetDB()
-	_, err := db.Exec("DELETE FROM products WHERE id = $1", id)
-	return err
+// PatchProduct applies a merge patch to the product when its version
+// satisfies pre. The patched product is validated as a whole, but only the
+// fields in the patch are written. A change in stock goes to the default
+// store, as in UpdateProduct.
+func PatchProduct(ctx context.Context, tenantID, id int, patch validation.Patch, pre Precondition) (*models.Product, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var product models.Product
+	err = tx.QueryRowContext(ctx, "SELECT id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version FROM products WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL FOR UPDATE", id, tenantID).Scan(&product.ID, &product.SKU, &product.Name, &product.Price, &product.Cost, &product.Stock, &product.CategoriesID, &product.Barcode, &product.Archived, &product.Version)
+	if err != nil {
+		return nil, notFound(err, "product", id)
+	}
+	if err = pre.check("product", id, product.Version); err != nil {
+		return nil, err
+	}
+
+	currentStock := product.Stock
+	if err = patch.Apply(&product); err != nil {
+		return nil, err
+	}
+	if err = patch.Restrict("sku", "name", "price", "cost", "stock", "categories_id", "barcode"); err != nil {
+		return nil, err
+	}
+
+	if patch.Has("categories_id") {
+		if err = categoryExists(ctx, tx, tenantID, product.CategoriesID); err != nil {
+			return nil, err
+		}
+	}
+	if patch.Has("sku") {
+		if err = skuAvailable(ctx, tx, tenantID, product.SKU, id); err != nil {
+			return nil, err
+		}
+	}
+
+	var storeID int
+	if product.Stock != currentStock {
+		storeID, err = defaultStoreID(ctx, tx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		shortfall, err := defaultStockShortfall(ctx, tx, storeID, id, product.Stock-currentStock)
+		if err != nil {
+			return nil, err
+		}
+		if shortfall != "" {
+			return nil, &ValidationError{Message: shortfall}
+		}
+	}
+
+	columns := newPatchColumns(patch, "version = version + 1")
+	columns.set("sku", "sku = NULLIF($%d, '')", product.SKU)
+	columns.set("name", "name = $%d", product.Name)
+	columns.set("price", "price = $%d", product.Price)
+	columns.set("cost", "cost = $%d", product.Cost)
+	columns.set("categories_id", "categories_id = $%d", product.CategoriesID)
+	columns.set("barcode", "barcode = NULLIF($%d, '')", product.Barcode)
+	if err = columns.exec(ctx, tx, "products", id); err != nil {
+		return nil, err
+	}
+
+	if product.Stock != currentStock {
+		if err = adjustStock(ctx, tx, storeID, id, product.Stock-currentStock); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version FROM products WHERE id = $1", id).Scan(&product.ID, &product.SKU, &product.Name, &product.Price, &product.Cost, &product.Stock, &product.CategoriesID, &product.Barcode, &product.Archived, &product.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	refreshSuggestions(ctx, tenantID, id)
+	return &product, nil
+}
+
+// GetArchivedProducts lists the products hidden from the catalog by
+// ArchiveProduct.
+func GetArchivedProducts(ctx context.Context, tenantID int) ([]models.Product, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version FROM products WHERE tenant_id = $1 AND archived_at IS NOT NULL AND deleted_at IS NULL ORDER BY id", tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var p models.Product
+		if err := rows.Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.Cost, &p.Stock, &p.CategoriesID, &p.Barcode, &p.Archived, &p.Version); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// DeleteProduct soft-deletes a product that was never sold or transferred
+// when its version satisfies pre; RestoreProduct brings it back until
+// PurgeDeleted removes it. A product with history is kept for the reports
+// and answered with a ConflictError counting its transactions and transfers;
+// archive it instead.
+func DeleteProduct(ctx context.Context, tenantID, id int, pre Precondition) error {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	err = tx.QueryRowContext(ctx, "SELECT version FROM products WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL FOR UPDATE", id, tenantID).Scan(&version)
+	if err != nil {
+		return notFound(err, "product", id)
+	}
+	if err = pre.check("product", id, version); err != nil {
+		return err
+	}
+
+	history, err := productHistory(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if len(history) > 0 {
+		return &ConflictError{
+			Message: "product has sales or transfer history; archive it instead",
+			Details: history,
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, deleted_at = (now() AT TIME ZONE 'UTC') WHERE id = $1", id); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	refreshSuggestions(ctx, tenantID, id)
+	return nil
+}
+
+// RestoreProduct undoes DeleteProduct. A product whose category is deleted
+// too cannot be restored on its own; restore the category instead.
+func RestoreProduct(ctx context.Context, tenantID, id int) (*models.Product, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deleted, categoryDeleted bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT p.deleted_at IS NOT NULL, c.deleted_at IS NOT NULL
+		FROM products p
+		JOIN categories c ON c.id = p.categories_id
+		WHERE p.id = $1 AND p.tenant_id = $2
+		FOR UPDATE OF p
+	`, id, tenantID).Scan(&deleted, &categoryDeleted)
+	if err != nil {
+		return nil, notFound(err, "product", id)
+	}
+	if !deleted {
+		return nil, &ConflictError{Message: "product is not deleted"}
+	}
+	if categoryDeleted {
+		return nil, &ConflictError{Message: "the product's category is deleted; restore the category first"}
+	}
+
+	var p models.Product
+	err = tx.QueryRowContext(ctx, "UPDATE products SET version = version + 1, deleted_at = NULL WHERE id = $1 RETURNING id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version", id).Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.Cost, &p.Stock, &p.CategoriesID, &p.Barcode, &p.Archived, &p.Version)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	refreshSuggestions(ctx, tenantID, id)
+	return &p, nil
+}
+
+// ArchiveProduct hides a product from the catalog, suggestions and checkout,
+// or brings it back when archived is false. Its stock and history are kept.
+func ArchiveProduct(ctx context.Context, tenantID, id int, archived bool) (*models.Product, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var p models.Product
+	err = db.QueryRowContext(ctx, `
+		UPDATE products
+		SET version = version + 1, archived_at = CASE WHEN $3::boolean THEN COALESCE(archived_at, (now() AT TIME ZONE 'UTC')) END
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		RETURNING id, COALESCE(sku, ''), name, price, cost, stock, categories_id, COALESCE(barcode, ''), archived_at IS NOT NULL, version
+	`, id, tenantID, archived).Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.Cost, &p.Stock, &p.CategoriesID, &p.Barcode, &p.Archived, &p.Version)
+	if err != nil {
+		return nil, notFound(err, "product", id)
+	}
+	refreshSuggestions(ctx, tenantID, id)
+	return &p, nil
+}
+
+// productHistory counts the transactions and stock transfers that reference
+// the product. The map is empty when the product can be deleted.
+func productHistory(ctx context.Context, tx *sql.Tx, productID int) (map[string]int, error) {
+	var transactions, transfers int
+	err := tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(DISTINCT transaction_id) FROM transaction_details WHERE product_id = $1),
+			(SELECT COUNT(DISTINCT transfer_id) FROM stock_transfer_items WHERE product_id = $1)
+	`, productID).Scan(&transactions, &transfers)
+	if err != nil {
+		return nil, err
+	}
+
+	history := map[string]int{}
+	if transactions > 0 {
+		history["transactions"] = transactions
+	}
+	if transfers > 0 {
+		history["transfers"] = transfers
+	}
+	return history, nil
+}
+
+func categoryExists(ctx context.Context, tx *sql.Tx, tenantID, categoryID int) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL)", categoryID, tenantID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &ValidationError{Message: "category not found"}
+	}
+	return nil
+}
+
+// productExists reports a ValidationError when the product does not belong to
+// the tenant.
+func productExists(ctx context.Context, tx *sql.Tx, tenantID, productID int) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL)", productID, tenantID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &ValidationError{Message: "product not found", ProductID: productID}
+	}
+	return nil
+}
+
+// skuAvailable reports a ConflictError when another product of the tenant
+// already uses the SKU. Deleted products keep their SKU until purged. An
+// empty SKU is always available.
+func skuAvailable(ctx context.Context, tx *sql.Tx, tenantID int, sku string, productID int) error {
+	if sku == "" {
+		return nil
+	}
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE tenant_id = $1 AND sku = $2 AND id <> $3)", tenantID, sku, productID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	return &ConflictError{Message: "sku already used by another product"}
 }
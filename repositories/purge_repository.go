@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"categories-api/database"
+)
+
+// PurgeResult counts the rows removed by PurgeDeleted.
+type PurgeResult struct {
+	Products   int64
+	Categories int64
+}
+
+// PurgeDeleted permanently removes the tenant's products and categories that
+// were soft-deleted before the cutoff. Their store stock and stock receipts
+// go with them. Products are purged first so a category is only removed once
+// none of its products is left.
+func PurgeDeleted(ctx context.Context, tenantID int, before time.Time) (*PurgeResult, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The history check repeats DeleteProduct's, in case a row was
+	// soft-deleted directly in the database.
+	products, err := tx.ExecContext(ctx, `
+		DELETE FROM products p
+		WHERE p.tenant_id = $1 AND p.deleted_at < $2
+			AND NOT EXISTS(SELECT 1 FROM transaction_details WHERE product_id = p.id)
+			AND NOT EXISTS(SELECT 1 FROM stock_transfer_items WHERE product_id = p.id)
+	`, tenantID, before.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := tx.ExecContext(ctx, `
+		DELETE FROM categories c
+		WHERE c.tenant_id = $1 AND c.deleted_at < $2
+			AND NOT EXISTS(SELECT 1 FROM products WHERE categories_id = c.id)
+	`, tenantID, before.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	var result PurgeResult
+	if result.Products, err = products.RowsAffected(); err != nil {
+		return nil, err
+	}
+	if result.Categories, err = categories.RowsAffected(); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
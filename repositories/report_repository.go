@@ -3,33 +3,59 @@ package repositories
 import (
 	"categories-api/database"
 	"categories-api/models"
+	"context"
 	"database/sql"
+	"fmt"
+	"math"
 	"time"
-)
 
-func GetTodayReport() (*models.DailyReport, error) {
-	now := time.Now()
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
-	endOfDay := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 999999999, time.UTC)
+	"github.com/spf13/viper"
+)
 
-	return GetDateRangeReportInternal(startOfDay, endOfDay)
+// ReportOptions narrows and shapes a report. StoreID limits the figures to
+// one store; 0 gives the consolidated view across all stores. Top and SortBy
+// control product rankings: Top 0 keeps the classic "all products tied for
+// the highest quantity" list in produk_terlaris. Timezone is an IANA zone
+// name used for day boundaries and bucketing; see reportLocation.
+type ReportOptions struct {
+	StoreID  int
+	Top      int
+	SortBy   string
+	Timezone string
 }
 
-func GetDateRangeReport(startDate, endDate string) (*models.DateRangeReport, error) {
+const (
+	SortByQuantity = "quantity"
+	SortByRevenue  = "revenue"
+)
 
-	start, err := time.Parse("2006-01-02", startDate)
+func GetTodayReport(ctx context.Context, tenantID int, opts ReportOptions) (*models.DailyReport, error) {
+	loc, err := reportLocation(ctx, tenantID, opts)
 	if err != nil {
 		return nil, err
 	}
+	opts.Timezone = loc.String()
 
-	end, err := time.Parse("2006-01-02", endDate)
+	now := time.Now().In(loc)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 999999999, loc)
+
+	return GetDateRangeReportInternal(ctx, tenantID, startOfDay.UTC(), endOfDay.UTC(), opts)
+}
+
+func GetDateRangeReport(ctx context.Context, tenantID int, startDate, endDate string, opts ReportOptions) (*models.DateRangeReport, error) {
+	loc, err := reportLocation(ctx, tenantID, opts)
 	if err != nil {
 		return nil, err
 	}
+	opts.Timezone = loc.String()
 
-	endDateOnly := time.Date(end.Year(), end.Month(), end.Day(), 23, 59, 59, 999999999, time.UTC)
+	start, endDateOnly, err := parseDateRange(startDate, endDate, loc)
+	if err != nil {
+		return nil, err
+	}
 
-	report, err := GetDateRangeReportInternal(start, endDateOnly)
+	report, err := GetDateRangeReportInternal(ctx, tenantID, start, endDateOnly, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -41,30 +67,83 @@ func GetDateRangeReport(startDate, endDate string) (*models.DateRangeReport, err
 	}, nil
 }
 
-func GetDateRangeReportInternal(startTime, endTime time.Time) (*models.DailyReport, error) {
-	db := database.GetDB()
+func GetDateRangeReportInternal(ctx context.Context, tenantID int, startTime, endTime time.Time, opts ReportOptions) (*models.DailyReport, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	period, err := newSalesPeriod(ctx, db, tenantID, startTime, endTime, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	var totalRevenue sql.NullInt64
 	var totalTransactions int
 
-	err := db.QueryRow("SELECT COALESCE(SUM(total_amount), 0) as total_revenue, COUNT(*) as total_transactions FROM transactions WHERE status = 'completed' AND created_at >= $1 AND created_at <= $2", startTime, endTime).Scan(&totalRevenue, &totalTransactions)
+	err = db.QueryRowContext(ctx, `
+		WITH `+salesTotals+`
+		SELECT COALESCE(SUM(revenue), 0) AS total_revenue, COALESCE(SUM(transactions), 0) AS total_transactions
+		FROM sales_totals
+	`, tenantID, period.RawFrom, period.RawTo, opts.StoreID, period.SummaryFrom, period.SummaryTo).Scan(&totalRevenue, &totalTransactions)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := db.Query(`
-		WITH product_sales AS (
-			SELECT p.name, SUM(td.quantity) as qty_sold
-			FROM transaction_details td
-			JOIN transactions t ON td.transaction_id = t.id
-			JOIN products p ON td.product_id = p.id
-			WHERE t.status = 'completed' AND t.created_at >= $1 AND t.created_at <= $2
+	var bestSellingProducts []models.BestSellingProduct
+	if opts.Top > 0 {
+		ranked, err := getProductSales(ctx, db, tenantID, period, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ranked {
+			bestSellingProducts = append(bestSellingProducts, models.BestSellingProduct{
+				ProductID: p.ProductID,
+				Name:      p.Name,
+				QtySold:   p.QtySold,
+				Revenue:   p.Revenue,
+			})
+		}
+	} else {
+		bestSellingProducts, err = getTopQuantityProducts(ctx, db, tenantID, period, opts.StoreID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := &models.DailyReport{
+		StoreID:             opts.StoreID,
+		Timezone:            opts.Timezone,
+		TotalRevenue:        int(totalRevenue.Int64),
+		TotalTransactions:   totalTransactions,
+		BestSellingProducts: bestSellingProducts,
+	}
+
+	if opts.StoreID == 0 {
+		report.Stores, err = getStoreSales(ctx, db, tenantID, period)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// getTopQuantityProducts returns every product tied for the highest quantity
+// sold in the period.
+func getTopQuantityProducts(ctx context.Context, db *database.TenantDB, tenantID int, period salesPeriod, storeID int) ([]models.BestSellingProduct, error) {
+	rows, err := db.QueryContext(ctx, `
+		WITH `+productLines+`, product_sales AS (
+			SELECT p.id, p.name, SUM(pl.qty_sold) as qty_sold, SUM(pl.revenue) as revenue
+			FROM product_lines pl
+			JOIN products p ON pl.product_id = p.id
 			GROUP BY p.id, p.name
 		)
-		SELECT name, qty_sold 
+		SELECT id, name, qty_sold, revenue
 		FROM product_sales 
 		WHERE qty_sold = (SELECT MAX(qty_sold) FROM product_sales)
-	`, startTime, endTime)
+	`, tenantID, period.RawFrom, period.RawTo, storeID, period.SummaryFrom, period.SummaryTo)
 	if err != nil {
 		return nil, err
 	}
@@ -73,18 +152,592 @@ func GetDateRangeReportInternal(startTime, endTime time.Time) (*models.DailyRepo
 	var bestSellingProducts []models.BestSellingProduct
 	for rows.Next() {
 		var p models.BestSellingProduct
-		err := rows.Scan(&p.Name, &p.QtySold)
-		if err != nil {
-			continue
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.QtySold, &p.Revenue); err != nil {
+			return nil, err
 		}
 		bestSellingProducts = append(bestSellingProducts, p)
 	}
+	return bestSellingProducts, rows.Err()
+}
 
-	report := &models.DailyReport{
-		TotalRevenue:        int(totalRevenue.Int64),
-		TotalTransactions:   totalTransactions,
-		BestSellingProducts: bestSellingProducts,
+func getStoreSales(ctx context.Context, db *database.TenantDB, tenantID int, period salesPeriod) ([]models.StoreSales, error) {
+
+	rows, err := db.QueryContext(ctx, `
+		WITH `+salesTotals+`
+		SELECT s.id, s.name, COALESCE(SUM(st.revenue), 0), COALESCE(SUM(st.transactions), 0)
+		FROM stores s
+		LEFT JOIN sales_totals st ON st.store_id = s.id
+		WHERE s.tenant_id = $1
+		GROUP BY s.id, s.name
+		ORDER BY s.id
+	`, tenantID, period.RawFrom, period.RawTo, 0, period.SummaryFrom, period.SummaryTo)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return report, nil
+	var stores []models.StoreSales
+	for rows.Next() {
+		var s models.StoreSales
+		if err := rows.Scan(&s.StoreID, &s.StoreName, &s.TotalRevenue, &s.TotalTransactions); err != nil {
+			return nil, err
+		}
+		stores = append(stores, s)
+	}
+	return stores, rows.Err()
+}
+
+const maxSalesBuckets = 10000
+
+// GetSalesTimeSeries returns revenue and transaction count per hour, day,
+// week (starting Monday) or month between the two dates. Buckets without
+// sales are included with zero values so charts have no gaps.
+func GetSalesTimeSeries(ctx context.Context, tenantID int, startDate, endDate, granularity string, opts ReportOptions) (*models.SalesTimeSeries, error) {
+	loc, err := reportLocation(ctx, tenantID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	start, endOfRange, err := parseDateRange(startDate, endDate, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var step func(time.Time) time.Time
+	switch granularity {
+	case "hour":
+		step = func(t time.Time) time.Time { return t.Add(time.Hour) }
+	case "day":
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case "week":
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	case "month":
+		step = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	default:
+		return nil, &ValidationError{Message: "granularity must be hour, day, week or month"}
+	}
+
+	var buckets []models.SalesBucket
+	index := map[time.Time]int{}
+	for t := truncateToBucket(start, granularity, loc); !t.After(endOfRange); t = step(t) {
+		if len(buckets) == maxSalesBuckets {
+			return nil, &ValidationError{Message: "date range too large for this granularity"}
+		}
+		index[t] = len(buckets)
+		buckets = append(buckets, models.SalesBucket{Period: t})
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// Hourly buckets need the time of each sale, which the daily summaries
+	// do not keep.
+	period := salesPeriod{RawFrom: start, RawTo: endOfRange}
+	if granularity != "hour" {
+		opts.Timezone = loc.String()
+		period, err = newSalesPeriod(ctx, db, tenantID, start, endOfRange, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		WITH sales AS (
+			SELECT date_trunc($7, created_at AT TIME ZONE 'UTC' AT TIME ZONE $8) AS period, total_amount AS revenue, 1 AS transactions
+			FROM transactions
+			WHERE tenant_id = $1 AND status = 'completed' AND created_at >= $2 AND created_at <= $3 AND ($4 = 0 OR store_id = $4)
+			UNION ALL
+			SELECT date_trunc($7, sales_date::timestamp), total_revenue, total_transactions
+			FROM daily_sales
+			WHERE tenant_id = $1 AND sales_date BETWEEN $5 AND $6 AND ($4 = 0 OR store_id = $4)
+		)
+		SELECT period, COALESCE(SUM(revenue), 0), COALESCE(SUM(transactions), 0)
+		FROM sales
+		GROUP BY period
+		ORDER BY period
+	`, tenantID, period.RawFrom, period.RawTo, opts.StoreID, period.SummaryFrom, period.SummaryTo, granularity, loc.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket time.Time
+		var revenue, count int
+		if err := rows.Scan(&bucket, &revenue, &count); err != nil {
+			return nil, err
+		}
+		// bucket is a wall-clock time in loc, returned without a zone.
+		bucket = time.Date(bucket.Year(), bucket.Month(), bucket.Day(), bucket.Hour(), 0, 0, 0, loc)
+		if i, ok := index[bucket]; ok {
+			buckets[i].TotalRevenue = revenue
+			buckets[i].TotalTransactions = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &models.SalesTimeSeries{
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Granularity: granularity,
+		StoreID:     opts.StoreID,
+		Timezone:    loc.String(),
+		Data:        buckets,
+	}, nil
+}
+
+// truncateToBucket mirrors Postgres date_trunc in loc for the supported
+// granularities.
+func truncateToBucket(t time.Time, granularity string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch granularity {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// parseDateRange turns two YYYY-MM-DD dates into the first and last instant
+// of that range of local days in loc. The instants are returned in UTC, the
+// zone created_at is stored in, because Postgres drops the offset when a
+// parameter is compared with a TIMESTAMP column.
+func parseDateRange(startDate, endDate string, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01-02", startDate, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, &ValidationError{Message: "invalid start_date, use YYYY-MM-DD"}
+	}
+	end, err := time.ParseInLocation("2006-01-02", endDate, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, &ValidationError{Message: "invalid end_date, use YYYY-MM-DD"}
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, &ValidationError{Message: "end_date must not be before start_date"}
+	}
+	endOfRange := time.Date(end.Year(), end.Month(), end.Day(), 23, 59, 59, 999999999, loc)
+	return start.UTC(), endOfRange.UTC(), nil
+}
+
+// reportLocation picks the zone a report is computed in: the explicit
+// Timezone option, else the timezone of the store (the tenant's default store
+// for consolidated reports), else STORE_TIMEZONE from config, else UTC.
+func reportLocation(ctx context.Context, tenantID int, opts ReportOptions) (*time.Location, error) {
+	if opts.Timezone != "" {
+		loc, err := time.LoadLocation(opts.Timezone)
+		if err != nil {
+			return nil, &ValidationError{Message: "unknown timezone " + opts.Timezone}
+		}
+		return loc, nil
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	storeID := opts.StoreID
+	if storeID == 0 {
+		storeID, err = defaultStoreID(ctx, db, tenantID)
+		if err != nil {
+			if _, ok := err.(*ValidationError); !ok {
+				return nil, err
+			}
+		}
+	}
+
+	var name string
+	err = db.QueryRowContext(ctx, "SELECT timezone FROM stores WHERE id = $1 AND tenant_id = $2", storeID, tenantID).Scan(&name)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return StoreLocation(name)
+}
+
+// StoreLocation loads a store's timezone, falling back to STORE_TIMEZONE and
+// then UTC when the store has none.
+func StoreLocation(name string) (*time.Location, error) {
+	if name == "" {
+		name = viper.GetString("STORE_TIMEZONE")
+	}
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("load timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// rankingOrder returns the ORDER BY expression for a ranking; only the two
+// known sort keys are ever interpolated into SQL.
+func rankingOrder(sortBy string) (string, error) {
+	switch sortBy {
+	case "", SortByQuantity:
+		return "qty_sold DESC, revenue DESC", nil
+	case SortByRevenue:
+		return "revenue DESC, qty_sold DESC", nil
+	default:
+		return "", &ValidationError{Message: "sort_by must be quantity or revenue"}
+	}
+}
+
+// GetProductSalesReport ranks the products sold in the period by quantity or
+// revenue and returns the top opts.Top of them.
+func GetProductSalesReport(ctx context.Context, tenantID int, startDate, endDate string, opts ReportOptions) (*models.ProductSalesReport, error) {
+	loc, err := reportLocation(ctx, tenantID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := parseDateRange(startDate, endDate, loc)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SortBy == "" {
+		opts.SortBy = SortByQuantity
+	}
+	opts.Timezone = loc.String()
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	period, err := newSalesPeriod(ctx, db, tenantID, start, end, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := getProductSales(ctx, db, tenantID, period, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ProductSalesReport{
+		StartDate: startDate,
+		EndDate:   endDate,
+		StoreID:   opts.StoreID,
+		Timezone:  loc.String(),
+		SortBy:    opts.SortBy,
+		Top:       opts.Top,
+		Data:      data,
+	}, nil
+}
+
+func getProductSales(ctx context.Context, db *database.TenantDB, tenantID int, period salesPeriod, opts ReportOptions) ([]models.ProductSales, error) {
+	order, err := rankingOrder(opts.SortBy)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		WITH `+productLines+`
+		SELECT p.id, p.name, p.categories_id, SUM(pl.qty_sold) AS qty_sold, SUM(pl.revenue) AS revenue
+		FROM product_lines pl
+		JOIN products p ON pl.product_id = p.id
+		GROUP BY p.id, p.name, p.categories_id
+		ORDER BY `+order+`, p.id
+		LIMIT NULLIF($7, 0)
+	`, tenantID, period.RawFrom, period.RawTo, opts.StoreID, period.SummaryFrom, period.SummaryTo, opts.Top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.ProductSales
+	for rows.Next() {
+		var p models.ProductSales
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.CategoryID, &p.QtySold, &p.Revenue); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// GetCategorySalesReport aggregates sales per product category and ranks the
+// categories by quantity or revenue.
+func GetCategorySalesReport(ctx context.Context, tenantID int, startDate, endDate string, opts ReportOptions) (*models.CategorySalesReport, error) {
+	loc, err := reportLocation(ctx, tenantID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := parseDateRange(startDate, endDate, loc)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SortBy == "" {
+		opts.SortBy = SortByRevenue
+	}
+	order, err := rankingOrder(opts.SortBy)
+	if err != nil {
+		return nil, err
+	}
+	opts.Timezone = loc.String()
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	period, err := newSalesPeriod(ctx, db, tenantID, start, end, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		WITH category_lines AS (
+			SELECT td.category_id, SUM(td.quantity) AS qty_sold, SUM(td.subtotal) AS revenue, COUNT(DISTINCT t.id) AS transactions
+			FROM transaction_details td
+			JOIN transactions t ON td.transaction_id = t.id
+			WHERE t.tenant_id = $1 AND t.status = 'completed' AND t.created_at >= $2 AND t.created_at <= $3 AND ($4 = 0 OR t.store_id = $4)
+			GROUP BY td.category_id
+			UNION ALL
+			SELECT category_id, qty_sold, revenue, total_transactions
+			FROM daily_category_sales
+			WHERE tenant_id = $1 AND sales_date BETWEEN $5 AND $6 AND ($4 = 0 OR store_id = $4)
+		)
+		SELECT c.id, c.name, SUM(cl.qty_sold) AS qty_sold, SUM(cl.revenue) AS revenue, SUM(cl.transactions)
+		FROM category_lines cl
+		JOIN categories c ON cl.category_id = c.id
+		GROUP BY c.id, c.name
+		ORDER BY `+order+`, c.id
+		LIMIT NULLIF($7, 0)
+	`, tenantID, period.RawFrom, period.RawTo, opts.StoreID, period.SummaryFrom, period.SummaryTo, opts.Top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.CategorySales
+	for rows.Next() {
+		var c models.CategorySales
+		if err := rows.Scan(&c.CategoryID, &c.Name, &c.QtySold, &c.Revenue, &c.TotalTransactions); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &models.CategorySalesReport{
+		StartDate: startDate,
+		EndDate:   endDate,
+		StoreID:   opts.StoreID,
+		Timezone:  loc.String(),
+		SortBy:    opts.SortBy,
+		Top:       opts.Top,
+		Data:      categories,
+	}, nil
+}
+
+// GetSlowMoversReport lists the products that sold least in the period,
+// starting with those that did not sell at all, together with the stock still
+// on hand (in the store when opts.StoreID is set).
+func GetSlowMoversReport(ctx context.Context, tenantID int, startDate, endDate string, opts ReportOptions) (*models.SlowMoversReport, error) {
+	loc, err := reportLocation(ctx, tenantID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := parseDateRange(startDate, endDate, loc)
+	if err != nil {
+		return nil, err
+	}
+	opts.Timezone = loc.String()
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	period, err := newSalesPeriod(ctx, db, tenantID, start, end, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		WITH `+productLines+`, product_sales AS (
+			SELECT product_id, SUM(qty_sold) AS qty_sold, SUM(revenue) AS revenue
+			FROM product_lines
+			GROUP BY product_id
+		)
+		SELECT p.id, p.name, p.categories_id, COALESCE(ps.qty_sold, 0), COALESCE(ps.revenue, 0),
+			CASE WHEN $4 = 0 THEN p.stock ELSE COALESCE(ss.stock, 0) END
+		FROM products p
+		LEFT JOIN product_sales ps ON ps.product_id = p.id
+		LEFT JOIN store_stocks ss ON ss.product_id = p.id AND ss.store_id = $4
+		WHERE p.tenant_id = $1 AND p.deleted_at IS NULL
+		ORDER BY COALESCE(ps.qty_sold, 0) ASC, COALESCE(ps.revenue, 0) ASC, p.id
+		LIMIT NULLIF($7, 0)
+	`, tenantID, period.RawFrom, period.RawTo, opts.StoreID, period.SummaryFrom, period.SummaryTo, opts.Top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.SlowMovingProduct
+	for rows.Next() {
+		var p models.SlowMovingProduct
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.CategoryID, &p.QtySold, &p.Revenue, &p.Stock); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &models.SlowMoversReport{
+		StartDate: startDate,
+		EndDate:   endDate,
+		StoreID:   opts.StoreID,
+		Timezone:  loc.String(),
+		Top:       opts.Top,
+		Data:      products,
+	}, nil
+}
+
+const (
+	CompareToPrevious = "previous"
+	CompareToLastYear = "last_year"
+)
+
+// GetReportComparison returns the report for the given range next to the
+// report for the period it is compared with: the same number of days right
+// before it (CompareToPrevious) or the same dates one year earlier
+// (CompareToLastYear). Deltas cover revenue, transaction count, average basket
+// value and the top opts.Top products of the current period.
+func GetReportComparison(ctx context.Context, tenantID int, startDate, endDate, compareTo string, opts ReportOptions) (*models.ReportComparison, error) {
+	loc, err := reportLocation(ctx, tenantID, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Timezone = loc.String()
+
+	if compareTo == "" {
+		compareTo = CompareToPrevious
+	}
+	prevStartDate, prevEndDate, err := comparisonRange(startDate, endDate, compareTo)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := GetDateRangeReport(ctx, tenantID, startDate, endDate, opts)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := GetDateRangeReport(ctx, tenantID, prevStartDate, prevEndDate, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	prevStart, prevEnd, err := parseDateRange(prevStartDate, prevEndDate, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	period, err := newSalesPeriod(ctx, db, tenantID, prevStart, prevEnd, opts)
+	if err != nil {
+		return nil, err
+	}
+	prevSales, err := getProductSales(ctx, db, tenantID, period, ReportOptions{StoreID: opts.StoreID})
+	if err != nil {
+		return nil, err
+	}
+	prevByProduct := map[int]models.ProductSales{}
+	for _, p := range prevSales {
+		prevByProduct[p.ProductID] = p
+	}
+
+	var topProducts []models.ProductComparison
+	for _, p := range current.BestSellingProducts {
+		prev := prevByProduct[p.ProductID]
+		topProducts = append(topProducts, models.ProductComparison{
+			ProductID: p.ProductID,
+			Name:      p.Name,
+			QtySold:   metricDelta(p.QtySold, prev.QtySold),
+			Revenue:   metricDelta(p.Revenue, prev.Revenue),
+		})
+	}
+
+	return &models.ReportComparison{
+		CompareTo: compareTo,
+		Current:   *current,
+		Previous:  *previous,
+		Delta: models.ReportDelta{
+			TotalRevenue:      metricDelta(current.TotalRevenue, previous.TotalRevenue),
+			TotalTransactions: metricDelta(current.TotalTransactions, previous.TotalTransactions),
+			AverageBasket:     metricDelta(averageBasket(&current.DailyReport), averageBasket(&previous.DailyReport)),
+		},
+		TopProducts: topProducts,
+	}, nil
+}
+
+// comparisonRange returns the YYYY-MM-DD range a report is compared with.
+func comparisonRange(startDate, endDate, compareTo string) (string, string, error) {
+	start, end, err := parseDateRange(startDate, endDate, time.UTC)
+	if err != nil {
+		return "", "", err
+	}
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+
+	var prevStart, prevEnd time.Time
+	switch compareTo {
+	case CompareToPrevious:
+		days := int(end.Sub(start).Hours()/24) + 1
+		prevEnd = start.AddDate(0, 0, -1)
+		prevStart = start.AddDate(0, 0, -days)
+	case CompareToLastYear:
+		prevStart = start.AddDate(-1, 0, 0)
+		prevEnd = end.AddDate(-1, 0, 0)
+	default:
+		return "", "", &ValidationError{Message: "compare_to must be previous or last_year"}
+	}
+	return prevStart.Format("2006-01-02"), prevEnd.Format("2006-01-02"), nil
+}
+
+func averageBasket(report *models.DailyReport) int {
+	if report.TotalTransactions == 0 {
+		return 0
+	}
+	return report.TotalRevenue / report.TotalTransactions
+}
+
+func metricDelta(current, previous int) models.MetricDelta {
+	delta := models.MetricDelta{
+		Current:  current,
+		Previous: previous,
+		Change:   current - previous,
+	}
+	if previous != 0 {
+		pct := math.Round(float64(current-previous)/float64(previous)*10000) / 100
+		delta.ChangePct = &pct
+	}
+	return delta
 }
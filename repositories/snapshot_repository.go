@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"bytes"
+	"categories-api/database"
+	"categories-api/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// CreateReportSnapshot freezes the DailyReport of a store for a local date
+// (YYYY-MM-DD in the store's timezone). The first call stores revision 1;
+// later calls store the next revision only when the figures have changed,
+// so late corrections are kept next to the original. created is false when
+// the latest revision is still accurate and was returned as is.
+func CreateReportSnapshot(ctx context.Context, tenantID, storeID int, date string) (snapshot *models.ReportSnapshot, created bool, err error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// Serializes snapshots of the same store so revisions are not taken twice.
+	var timezone string
+	err = tx.QueryRowContext(ctx, "SELECT timezone FROM stores WHERE id = $1 AND tenant_id = $2 FOR UPDATE", storeID, tenantID).Scan(&timezone)
+	if err != nil {
+		return nil, false, notFound(err, "store", storeID)
+	}
+
+	loc, err := StoreLocation(timezone)
+	if err != nil {
+		return nil, false, err
+	}
+	start, end, err := parseDateRange(date, date, loc)
+	if err != nil {
+		return nil, false, &ValidationError{Message: "invalid date, use YYYY-MM-DD"}
+	}
+
+	report, err := GetDateRangeReportInternal(ctx, tenantID, start, end, ReportOptions{StoreID: storeID, Timezone: loc.String()})
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, false, err
+	}
+
+	latest, err := scanSnapshot(tx.QueryRowContext(ctx, `
+		SELECT id, store_id, report_date, revision, created_at, report
+		FROM report_snapshots
+		WHERE tenant_id = $1 AND store_id = $2 AND report_date = $3
+		ORDER BY revision DESC
+		LIMIT 1
+	`, tenantID, storeID, date))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	revision := 1
+	if latest != nil {
+		previous, err := json.Marshal(latest.Report)
+		if err != nil {
+			return nil, false, err
+		}
+		if bytes.Equal(previous, data) {
+			return latest, false, nil
+		}
+		revision = latest.Revision + 1
+	}
+
+	snapshot, err = scanSnapshot(tx.QueryRowContext(ctx, `
+		INSERT INTO report_snapshots (tenant_id, store_id, report_date, revision, report)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, store_id, report_date, revision, created_at, report
+	`, tenantID, storeID, date, revision, string(data)))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return snapshot, true, nil
+}
+
+// GetReportSnapshots returns the snapshots of a date, one per store. Without
+// a revision the latest revision of each store is returned. storeID 0 means
+// all stores.
+func GetReportSnapshots(ctx context.Context, tenantID int, date string, storeID, revision int) ([]models.ReportSnapshot, error) {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil, &ValidationError{Message: "invalid date, use YYYY-MM-DD"}
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT ON (store_id) id, store_id, report_date, revision, created_at, report
+		FROM report_snapshots
+		WHERE tenant_id = $1 AND report_date = $2 AND ($3 = 0 OR store_id = $3) AND ($4 = 0 OR revision = $4)
+		ORDER BY store_id, revision DESC
+	`, tenantID, date, storeID, revision)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []models.ReportSnapshot
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *s)
+	}
+	return snapshots, rows.Err()
+}
+
+// HasReportSnapshot reports whether any revision exists for the store and
+// date.
+func HasReportSnapshot(ctx context.Context, tenantID, storeID int, date string) (bool, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM report_snapshots WHERE tenant_id = $1 AND store_id = $2 AND report_date = $3)", tenantID, storeID, date).Scan(&exists)
+	return exists, err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSnapshot(row rowScanner) (*models.ReportSnapshot, error) {
+	var s models.ReportSnapshot
+	var reportDate time.Time
+	var data []byte
+	err := row.Scan(&s.ID, &s.StoreID, &reportDate, &s.Revision, &s.CreatedAt, &data)
+	if err != nil {
+		return nil, err
+	}
+	s.ReportDate = reportDate.Format("2006-01-02")
+	if err := json.Unmarshal(data, &s.Report); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
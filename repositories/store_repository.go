@@ -0,0 +1,628 @@
+package repositories
+
+import (
+	"categories-api/database"
+	"categories-api/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// defaultStoreID returns the tenant's first store. It is used when a request
+// does not name a store, and holds the stock set through the product endpoints.
+func defaultStoreID(ctx context.Context, q rowQueryer, tenantID int) (int, error) {
+	var id sql.NullInt64
+	err := q.QueryRowContext(ctx, "SELECT MIN(id) FROM stores WHERE tenant_id = $1", tenantID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if !id.Valid {
+		return 0, &ValidationError{Message: "tenant has no store"}
+	}
+	return int(id.Int64), nil
+}
+
+func GetAllStores(ctx context.Context, tenantID int) ([]models.Store, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, name, COALESCE(address, ''), COALESCE(phone, ''), timezone, created_at, updated_at FROM stores WHERE tenant_id = $1 ORDER BY id", tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stores []models.Store
+	for rows.Next() {
+		var s models.Store
+		if err := rows.Scan(&s.ID, &s.Name, &s.Address, &s.Phone, &s.Timezone, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		stores = append(stores, s)
+	}
+	return stores, rows.Err()
+}
+
+func GetStoreByID(ctx context.Context, tenantID, id int) (*models.Store, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var s models.Store
+	err = db.QueryRowContext(ctx, "SELECT id, name, COALESCE(address, ''), COALESCE(phone, ''), timezone, created_at, updated_at FROM stores WHERE id = $1 AND tenant_id = $2", id, tenantID).Scan(&s.ID, &s.Name, &s.Address, &s.Phone, &s.Timezone, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err, "store", id)
+	}
+	return &s, nil
+}
+
+func CreateStore(ctx context.Context, tenantID int, store models.Store) (*models.Store, error) {
+	if err := validateTimezone(store.Timezone); err != nil {
+		return nil, err
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	err = db.QueryRowContext(ctx, "INSERT INTO stores (tenant_id, name, address, phone, timezone, updated_at) VALUES ($1, $2, $3, $4, $5, (now() AT TIME ZONE 'UTC')) RETURNING id, name, COALESCE(address, ''), COALESCE(phone, ''), timezone, created_at, updated_at", tenantID, store.Name, store.Address, store.Phone, store.Timezone).Scan(&store.ID, &store.Name, &store.Address, &store.Phone, &store.Timezone, &store.CreatedAt, &store.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+func UpdateStore(ctx context.Context, tenantID, id int, store models.Store) (*models.Store, error) {
+	if err := validateTimezone(store.Timezone); err != nil {
+		return nil, err
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	err = db.QueryRowContext(ctx, "UPDATE stores SET name = $1, address = $2, phone = $3, timezone = $4, updated_at = (now() AT TIME ZONE 'UTC') WHERE id = $5 AND tenant_id = $6 RETURNING id, name, COALESCE(address, ''), COALESCE(phone, ''), timezone, created_at, updated_at", store.Name, store.Address, store.Phone, store.Timezone, id, tenantID).Scan(&store.ID, &store.Name, &store.Address, &store.Phone, &store.Timezone, &store.CreatedAt, &store.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err, "store", id)
+	}
+	return &store, nil
+}
+
+// DeleteStore removes a store. A store still holding stock or with transfers
+// in transit is refused with a ConflictError counting them, since deleting
+// its stock rows would leave the products' consolidated stock too high.
+func DeleteStore(ctx context.Context, tenantID, id int) error {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var stock, openTransfers int
+	err = tx.QueryRowContext(ctx, `
+		SELECT (SELECT COALESCE(SUM(stock), 0) FROM store_stocks WHERE store_id = s.id),
+			(SELECT COUNT(*) FROM stock_transfers WHERE (from_store_id = s.id OR to_store_id = s.id) AND status = $3)
+		FROM stores s
+		WHERE s.id = $1 AND s.tenant_id = $2
+		FOR UPDATE
+	`, id, tenantID, models.TransferStatusInTransit).Scan(&stock, &openTransfers)
+	if err != nil {
+		return notFound(err, "store", id)
+	}
+	if stock > 0 || openTransfers > 0 {
+		return &ConflictError{
+			Message: "store still holds stock or has transfers in transit; move the stock and receive or cancel the transfers first",
+			Details: map[string]int{"stock": stock, "open_transfers": openTransfers},
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM stores WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func GetStoreStock(ctx context.Context, tenantID, storeID int) ([]models.StoreStock, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT ss.store_id, ss.product_id, p.name, ss.stock
+		FROM store_stocks ss
+		JOIN stores s ON ss.store_id = s.id
+		JOIN products p ON ss.product_id = p.id
+		WHERE ss.store_id = $1 AND s.tenant_id = $2 AND p.deleted_at IS NULL
+		ORDER BY ss.product_id
+	`, storeID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stocks []models.StoreStock
+	for rows.Next() {
+		var s models.StoreStock
+		if err := rows.Scan(&s.StoreID, &s.ProductID, &s.ProductName, &s.Stock); err != nil {
+			return nil, err
+		}
+		stocks = append(stocks, s)
+	}
+	return stocks, rows.Err()
+}
+
+// SetStoreStock sets the stock level of a product in a store, e.g. after a
+// stock count, and adjusts the consolidated products.stock by the difference.
+func SetStoreStock(ctx context.Context, tenantID, storeID, productID, stock int) (*models.StoreStock, error) {
+	if stock < 0 {
+		return nil, &ValidationError{Message: "stock must not be negative"}
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = storeExists(ctx, tx, tenantID, storeID); err != nil {
+		return nil, err
+	}
+
+	var name string
+	err = tx.QueryRowContext(ctx, "SELECT name FROM products WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL FOR UPDATE", productID, tenantID).Scan(&name)
+	if err != nil {
+		return nil, &ValidationError{Message: "product not found", ProductID: productID}
+	}
+
+	current, err := lockStoreStock(ctx, tx, storeID, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = adjustStock(ctx, tx, storeID, productID, stock-current); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	refreshSuggestions(ctx, tenantID, productID)
+
+	return &models.StoreStock{
+		StoreID:     storeID,
+		ProductID:   productID,
+		ProductName: name,
+		Stock:       stock,
+	}, nil
+}
+
+func GetAllTransfers(ctx context.Context, tenantID int) ([]models.StockTransfer, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, from_store_id, to_store_id, status, COALESCE(note, ''), created_at, received_at FROM stock_transfers WHERE tenant_id = $1 ORDER BY id DESC", tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []models.StockTransfer
+	for rows.Next() {
+		var t models.StockTransfer
+		if err := rows.Scan(&t.ID, &t.FromStoreID, &t.ToStoreID, &t.Status, &t.Note, &t.CreatedAt, &t.ReceivedAt); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
+
+func GetTransferByID(ctx context.Context, tenantID, id int) (*models.StockTransfer, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var t models.StockTransfer
+	err = db.QueryRowContext(ctx, "SELECT id, from_store_id, to_store_id, status, COALESCE(note, ''), created_at, received_at FROM stock_transfers WHERE id = $1 AND tenant_id = $2", id, tenantID).Scan(&t.ID, &t.FromStoreID, &t.ToStoreID, &t.Status, &t.Note, &t.CreatedAt, &t.ReceivedAt)
+	if err != nil {
+		return nil, notFound(err, "transfer", id)
+	}
+
+	t.Items, err = getTransferItems(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateTransfer takes the items out of the source store and leaves them in
+// transit until the destination receives them.
+func CreateTransfer(ctx context.Context, tenantID int, req models.StockTransferRequest) (*models.StockTransfer, error) {
+	if req.FromStoreID == req.ToStoreID {
+		return nil, &ValidationError{Message: "source and destination store must differ"}
+	}
+	if len(req.Items) == 0 {
+		return nil, &ValidationError{Message: "transfer has no items"}
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = storeExists(ctx, tx, tenantID, req.FromStoreID); err != nil {
+		return nil, err
+	}
+	if err = storeExists(ctx, tx, tenantID, req.ToStoreID); err != nil {
+		return nil, err
+	}
+
+	var lots []stockLot
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, &ValidationError{Message: "quantity must be greater than zero", ProductID: item.ProductID}
+		}
+
+		if err = productExists(ctx, tx, tenantID, item.ProductID); err != nil {
+			return nil, err
+		}
+
+		available, err := lockStoreStock(ctx, tx, req.FromStoreID, item.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if available < item.Quantity {
+			return nil, &InsufficientStockError{
+				ProductID: item.ProductID,
+				Requested: item.Quantity,
+				Available: available,
+			}
+		}
+
+		itemLots, err := departingLots(ctx, tx, req.FromStoreID, item.ProductID, available, item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, itemLots...)
+
+		if err = adjustStock(ctx, tx, req.FromStoreID, item.ProductID, -item.Quantity); err != nil {
+			return nil, err
+		}
+	}
+
+	var transferID int
+	err = tx.QueryRowContext(ctx, "INSERT INTO stock_transfers (tenant_id, from_store_id, to_store_id, status, note) VALUES ($1, $2, $3, $4, $5) RETURNING id", tenantID, req.FromStoreID, req.ToStoreID, models.TransferStatusInTransit, req.Note).Scan(&transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range req.Items {
+		_, err = tx.ExecContext(ctx, "INSERT INTO stock_transfer_items (transfer_id, product_id, quantity) VALUES ($1, $2, $3)", transferID, item.ProductID, item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, lot := range lots {
+		_, err = tx.ExecContext(ctx, "INSERT INTO stock_transfer_lots (transfer_id, product_id, quantity, received_at) VALUES ($1, $2, $3, $4)", transferID, lot.ProductID, lot.Quantity, lot.ReceivedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]int, len(req.Items))
+	for i, item := range req.Items {
+		productIDs[i] = item.ProductID
+	}
+	refreshSuggestions(ctx, tenantID, productIDs...)
+
+	return GetTransferByID(ctx, tenantID, transferID)
+}
+
+// ReceiveTransfer books in-transit items into the destination store. They
+// keep the receipt dates they had in the source store.
+func ReceiveTransfer(ctx context.Context, tenantID, id int) (*models.StockTransfer, error) {
+	return completeTransfer(ctx, tenantID, id, models.TransferStatusReceived)
+}
+
+// CancelTransfer returns in-transit items to the source store, where their
+// receipts were never removed.
+func CancelTransfer(ctx context.Context, tenantID, id int) (*models.StockTransfer, error) {
+	return completeTransfer(ctx, tenantID, id, models.TransferStatusCancelled)
+}
+
+func completeTransfer(ctx context.Context, tenantID, id int, status string) (*models.StockTransfer, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var fromStoreID, toStoreID int
+	var currentStatus string
+	err = tx.QueryRowContext(ctx, "SELECT from_store_id, to_store_id, status FROM stock_transfers WHERE id = $1 AND tenant_id = $2 FOR UPDATE", id, tenantID).Scan(&fromStoreID, &toStoreID, &currentStatus)
+	if err != nil {
+		return nil, notFound(err, "transfer", id)
+	}
+	if currentStatus != models.TransferStatusInTransit {
+		return nil, &ConflictError{Message: "transfer is already " + currentStatus}
+	}
+
+	storeID := toStoreID
+	if status == models.TransferStatusCancelled {
+		storeID = fromStoreID
+	}
+
+	items, err := getTransferItems(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if err = changeStock(ctx, tx, storeID, item.ProductID, item.Quantity); err != nil {
+			return nil, err
+		}
+	}
+	if status == models.TransferStatusReceived {
+		if err = receiveTransferLots(ctx, tx, id, toStoreID, items); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE stock_transfers SET status = $1, received_at = CASE WHEN $1 = 'received' THEN (now() AT TIME ZONE 'UTC') END WHERE id = $2", status, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]int, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	refreshSuggestions(ctx, tenantID, productIDs...)
+
+	return GetTransferByID(ctx, tenantID, id)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func getTransferItems(ctx context.Context, q queryer, transferID int) ([]models.StockTransferItem, error) {
+	rows, err := q.QueryContext(ctx, "SELECT product_id, quantity FROM stock_transfer_items WHERE transfer_id = $1 ORDER BY id", transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.StockTransferItem
+	for rows.Next() {
+		var item models.StockTransferItem
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func storeExists(ctx context.Context, tx *sql.Tx, tenantID, storeID int) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM stores WHERE id = $1 AND tenant_id = $2)", storeID, tenantID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &ValidationError{Message: "store not found"}
+	}
+	return nil
+}
+
+// lockStoreStock returns the current stock of a product in a store and locks
+// the row for the rest of the transaction. A missing row counts as zero.
+func lockStoreStock(ctx context.Context, tx *sql.Tx, storeID, productID int) (int, error) {
+	var stock int
+	err := tx.QueryRowContext(ctx, "SELECT stock FROM store_stocks WHERE store_id = $1 AND product_id = $2 FOR UPDATE", storeID, productID).Scan(&stock)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return stock, err
+}
+
+// defaultStockShortfall locks the product's stock in the default store and
+// returns a message when a change of delta in the product's total stock would
+// take that store below zero, or "" when the change fits. Total stock is only
+// ever changed through the default store.
+func defaultStockShortfall(ctx context.Context, tx *sql.Tx, storeID, productID, delta int) (string, error) {
+	storeStock, err := lockStoreStock(ctx, tx, storeID, productID)
+	if err != nil {
+		return "", err
+	}
+	if storeStock+delta < 0 {
+		return fmt.Sprintf("stock can be reduced by at most %d, the stock in the default store", storeStock), nil
+	}
+	return "", nil
+}
+
+// adjustStock changes the stock of a product in a store by delta and keeps
+// products.stock equal to the total on hand across all stores. Increases are
+// recorded as stock receipts.
+func adjustStock(ctx context.Context, tx *sql.Tx, storeID, productID, delta int) error {
+	if err := changeStock(ctx, tx, storeID, productID, delta); err != nil {
+		return err
+	}
+	return recordStockReceipt(ctx, tx, storeID, productID, delta)
+}
+
+// changeStock is adjustStock without the stock receipt, for stock that
+// arrives with receipts of its own, such as a transfer.
+func changeStock(ctx context.Context, tx *sql.Tx, storeID, productID, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO store_stocks (store_id, product_id, stock) VALUES ($1, $2, $3)
+		ON CONFLICT (store_id, product_id) DO UPDATE SET stock = store_stocks.stock + EXCLUDED.stock
+	`, storeID, productID, delta)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, stock = stock + $1 WHERE id = $2", delta, productID)
+	return err
+}
+
+// recordStockReceipt remembers when stock arrived in a store so the inventory
+// report can age it. Decreases are not recorded; the report consumes the
+// oldest receipts first.
+func recordStockReceipt(ctx context.Context, tx *sql.Tx, storeID, productID, quantity int) error {
+	if quantity <= 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO stock_receipts (store_id, product_id, quantity) VALUES ($1, $2, $3)", storeID, productID, quantity)
+	return err
+}
+
+// stockLot is a quantity of a product with the date it was received, as
+// kept in stock_receipts.
+type stockLot struct {
+	ProductID  int
+	Quantity   int
+	ReceivedAt time.Time
+}
+
+// departingLots returns the receipts that quantity units of a product take
+// with them when they leave a store holding onHand units. The inventory
+// report ages stock FIFO, so these are the oldest lots still on hand. Units
+// not covered by any receipt get no lot.
+func departingLots(ctx context.Context, tx *sql.Tx, storeID, productID, onHand, quantity int) ([]stockLot, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT received_at, LEAST(quantity, $3 - (received_since - quantity))
+		FROM (
+			SELECT id, quantity, received_at,
+				SUM(quantity) OVER (ORDER BY received_at DESC, id DESC) AS received_since
+			FROM stock_receipts
+			WHERE store_id = $1 AND product_id = $2
+		) lots
+		WHERE received_since - quantity < $3
+		ORDER BY received_at, id
+	`, storeID, productID, onHand)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []stockLot
+	for quantity > 0 && rows.Next() {
+		lot := stockLot{ProductID: productID}
+		if err := rows.Scan(&lot.ReceivedAt, &lot.Quantity); err != nil {
+			return nil, err
+		}
+		lot.Quantity = min(lot.Quantity, quantity)
+		quantity -= lot.Quantity
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// receiveTransferLots records the stock of a received transfer as receipts
+// of the destination store, dated as they were in the source store. Items
+// without lots, such as those of transfers made before lots were kept, are
+// recorded as received now.
+func receiveTransferLots(ctx context.Context, tx *sql.Tx, transferID, storeID int, items []models.StockTransferItem) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO stock_receipts (store_id, product_id, quantity, received_at)
+		SELECT $1, product_id, quantity, received_at FROM stock_transfer_lots WHERE transfer_id = $2
+	`, storeID, transferID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT product_id, SUM(quantity) FROM stock_transfer_lots WHERE transfer_id = $1 GROUP BY product_id", transferID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tracked := map[int]int{}
+	for rows.Next() {
+		var productID, quantity int
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return err
+		}
+		tracked[productID] = quantity
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, item := range items {
+		covered := min(tracked[item.ProductID], item.Quantity)
+		tracked[item.ProductID] -= covered
+		if err := recordStockReceipt(ctx, tx, storeID, item.ProductID, item.Quantity-covered); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return &ValidationError{Message: "unknown timezone " + name}
+	}
+	return nil
+}
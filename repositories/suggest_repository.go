@@ -0,0 +1,271 @@
+package repositories
+
+import (
+	"categories-api/database"
+	"categories-api/models"
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// suggestIndexTTL is how long a tenant's suggestion index is trusted. Writes
+// made through this process refresh it right away; the TTL picks up changes
+// made by other instances or directly in the database.
+const suggestIndexTTL = 5 * time.Minute
+
+// MaxSuggestions caps the number of suggestions returned at once.
+const MaxSuggestions = 50
+
+type suggestEntry struct {
+	product models.ProductSuggestion
+	name    string
+	sku     string
+	words   []string
+}
+
+type tenantSuggestions struct {
+	entries  map[int]suggestEntry
+	loadedAt time.Time
+}
+
+// suggestions holds an in-memory copy of every tenant's products for
+// type-ahead. version is bumped on every write so a load that raced with a
+// write is thrown away instead of overwriting newer data.
+var suggestions = struct {
+	sync.RWMutex
+	tenants map[int]*tenantSuggestions
+	loading map[int]bool
+	version map[int]int
+}{
+	tenants: map[int]*tenantSuggestions{},
+	loading: map[int]bool{},
+	version: map[int]int{},
+}
+
+// SuggestProducts returns up to limit products whose name, SKU or barcode
+// words start with every word of the query: exact SKU or barcode first, then
+// names starting with the query, then by name. It answers from memory and
+// falls back to the database while a tenant's index is missing or stale.
+func SuggestProducts(ctx context.Context, tenantID int, query string, limit int) ([]models.ProductSuggestion, error) {
+	words := searchWords(query)
+	if len(words) == 0 {
+		return []models.ProductSuggestion{}, nil
+	}
+
+	suggestions.RLock()
+	index := suggestions.tenants[tenantID]
+	if index != nil && time.Since(index.loadedAt) < suggestIndexTTL {
+		result := index.search(strings.ToLower(strings.TrimSpace(query)), words, limit)
+		suggestions.RUnlock()
+		return result, nil
+	}
+	suggestions.RUnlock()
+
+	// The load outlives the request that noticed the stale index.
+	go loadSuggestions(context.WithoutCancel(ctx), tenantID)
+	return suggestFromDatabase(ctx, tenantID, query, limit)
+}
+
+func (t *tenantSuggestions) search(query string, words []string, limit int) []models.ProductSuggestion {
+	type match struct {
+		entry suggestEntry
+		rank  int
+	}
+	var matches []match
+
+	for _, entry := range t.entries {
+		if !hasWordPrefixes(entry.words, words) {
+			continue
+		}
+		rank := 2
+		if query == entry.sku || query == strings.ToLower(entry.product.Barcode) {
+			rank = 0
+		} else if strings.HasPrefix(entry.name, query) {
+			rank = 1
+		}
+		matches = append(matches, match{entry, rank})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].rank != matches[j].rank {
+			return matches[i].rank < matches[j].rank
+		}
+		if matches[i].entry.name != matches[j].entry.name {
+			return matches[i].entry.name < matches[j].entry.name
+		}
+		return matches[i].entry.product.ID < matches[j].entry.product.ID
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	result := make([]models.ProductSuggestion, len(matches))
+	for i, m := range matches {
+		result[i] = m.entry.product
+	}
+	return result
+}
+
+// hasWordPrefixes reports whether every query word starts some word of the
+// product.
+func hasWordPrefixes(productWords, queryWords []string) bool {
+	for _, q := range queryWords {
+		found := false
+		for _, w := range productWords {
+			if strings.HasPrefix(w, q) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func newSuggestEntry(p models.ProductSuggestion, sku string) suggestEntry {
+	return suggestEntry{
+		product: p,
+		name:    strings.ToLower(p.Name),
+		sku:     strings.ToLower(sku),
+		words:   searchWords(p.Name + " " + sku + " " + p.Barcode),
+	}
+}
+
+// loadSuggestions (re)builds the index of a tenant. Only one load per tenant
+// runs at a time.
+func loadSuggestions(ctx context.Context, tenantID int) {
+	suggestions.Lock()
+	if suggestions.loading[tenantID] {
+		suggestions.Unlock()
+		return
+	}
+	suggestions.loading[tenantID] = true
+	version := suggestions.version[tenantID]
+	suggestions.Unlock()
+
+	entries, err := querySuggestEntries(ctx, tenantID, nil)
+
+	suggestions.Lock()
+	defer suggestions.Unlock()
+	delete(suggestions.loading, tenantID)
+	if err != nil {
+		log.Printf("suggest: loading tenant %d: %v", tenantID, err)
+		return
+	}
+	if suggestions.version[tenantID] != version {
+		return
+	}
+	suggestions.tenants[tenantID] = &tenantSuggestions{entries: entries, loadedAt: time.Now()}
+}
+
+// refreshSuggestions reloads the given products into the tenant's index
+// after a committed write, dropping the ones that no longer exist.
+func refreshSuggestions(ctx context.Context, tenantID int, productIDs ...int) {
+	suggestions.Lock()
+	suggestions.version[tenantID]++
+	_, indexed := suggestions.tenants[tenantID]
+	suggestions.Unlock()
+	if !indexed || len(productIDs) == 0 {
+		return
+	}
+
+	entries, err := querySuggestEntries(ctx, tenantID, productIDs)
+
+	suggestions.Lock()
+	defer suggestions.Unlock()
+	index := suggestions.tenants[tenantID]
+	if index == nil {
+		return
+	}
+	if err != nil {
+		log.Printf("suggest: refreshing tenant %d: %v", tenantID, err)
+		delete(suggestions.tenants, tenantID)
+		return
+	}
+	for _, id := range productIDs {
+		if entry, ok := entries[id]; ok {
+			index.entries[id] = entry
+		} else {
+			delete(index.entries, id)
+		}
+	}
+}
+
+// forgetSuggestions drops a tenant's index after a write that touched too
+// many products to refresh one by one; the next suggestion reloads it.
+func forgetSuggestions(tenantID int) {
+	suggestions.Lock()
+	defer suggestions.Unlock()
+	suggestions.version[tenantID]++
+	delete(suggestions.tenants, tenantID)
+}
+
+// querySuggestEntries reads the given products of a tenant, or all of them
+// when productIDs is nil.
+func querySuggestEntries(ctx context.Context, tenantID int, productIDs []int) (map[int]suggestEntry, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, name, price, stock, COALESCE(barcode, ''), COALESCE(sku, '') FROM products WHERE tenant_id = $1 AND archived_at IS NULL AND deleted_at IS NULL AND ($2::int[] IS NULL OR id = ANY($2))", tenantID, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := map[int]suggestEntry{}
+	for rows.Next() {
+		var p models.ProductSuggestion
+		var sku string
+		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.Barcode, &sku); err != nil {
+			return nil, err
+		}
+		entries[p.ID] = newSuggestEntry(p, sku)
+	}
+	return entries, rows.Err()
+}
+
+// suggestFromDatabase answers a suggestion while the index is not ready,
+// using the same prefix search as SearchProducts plus exact barcode lookup.
+func suggestFromDatabase(ctx context.Context, tenantID int, query string, limit int) ([]models.ProductSuggestion, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	query = strings.TrimSpace(query)
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, name, price, stock, COALESCE(barcode, '')
+		FROM products
+		WHERE tenant_id = $1 AND archived_at IS NULL AND deleted_at IS NULL AND (
+			to_tsvector('simple', name || ' ' || COALESCE(sku, '')) @@ to_tsquery('simple', $2)
+			OR barcode = $3
+		)
+		ORDER BY (LOWER(sku) = LOWER($3) OR barcode = $3) IS TRUE DESC, LOWER(name) LIKE LOWER($3) || '%' DESC, LOWER(name), id
+		LIMIT $4
+	`, tenantID, prefixTSQuery(query), query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.ProductSuggestion{}
+	for rows.Next() {
+		var p models.ProductSuggestion
+		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.Barcode); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,242 @@
+package repositories
+
+import (
+	"categories-api/database"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// salesDate is the local day of a transaction t in the timezone of its store
+// s, falling back to the zone passed as $2.
+const salesDate = `(t.created_at AT TIME ZONE 'UTC' AT TIME ZONE COALESCE(NULLIF(s.timezone, ''), $2))::date`
+
+// fallbackZone is the zone used for stores without a timezone of their own.
+func fallbackZone() (string, error) {
+	loc, err := StoreLocation("")
+	if err != nil {
+		return "", err
+	}
+	return loc.String(), nil
+}
+
+// applyDailySummaries adds a transaction to the daily summary tables. It runs
+// inside the transaction that records the sale so the summaries never drift.
+func applyDailySummaries(ctx context.Context, tx *sql.Tx, transactionID int) error {
+	zone, err := fallbackZone()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO daily_sales (tenant_id, store_id, sales_date, payment_method, total_revenue, total_transactions)
+		SELECT t.tenant_id, t.store_id, `+salesDate+`, t.payment_method, t.total_amount, 1
+		FROM transactions t
+		JOIN stores s ON t.store_id = s.id
+		WHERE t.id = $1
+		ON CONFLICT (store_id, sales_date, payment_method) DO UPDATE SET
+			total_revenue = daily_sales.total_revenue + EXCLUDED.total_revenue,
+			total_transactions = daily_sales.total_transactions + EXCLUDED.total_transactions
+	`, transactionID, zone)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO daily_product_sales (tenant_id, store_id, sales_date, product_id, category_id, payment_method, qty_sold, revenue, total_transactions)
+		SELECT t.tenant_id, t.store_id, `+salesDate+`, td.product_id, td.category_id, t.payment_method, SUM(td.quantity), SUM(td.subtotal), 1
+		FROM transaction_details td
+		JOIN transactions t ON td.transaction_id = t.id
+		JOIN stores s ON t.store_id = s.id
+		WHERE t.id = $1
+		GROUP BY t.tenant_id, t.store_id, 3, td.product_id, td.category_id, t.payment_method
+		ON CONFLICT (store_id, sales_date, product_id, payment_method) DO UPDATE SET
+			qty_sold = daily_product_sales.qty_sold + EXCLUDED.qty_sold,
+			revenue = daily_product_sales.revenue + EXCLUDED.revenue,
+			total_transactions = daily_product_sales.total_transactions + EXCLUDED.total_transactions
+	`, transactionID, zone)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO daily_category_sales (tenant_id, store_id, sales_date, category_id, payment_method, qty_sold, revenue, total_transactions)
+		SELECT t.tenant_id, t.store_id, `+salesDate+`, td.category_id, t.payment_method, SUM(td.quantity), SUM(td.subtotal), 1
+		FROM transaction_details td
+		JOIN transactions t ON td.transaction_id = t.id
+		JOIN stores s ON t.store_id = s.id
+		WHERE t.id = $1
+		GROUP BY t.tenant_id, t.store_id, 3, td.category_id, t.payment_method
+		ON CONFLICT (store_id, sales_date, category_id, payment_method) DO UPDATE SET
+			qty_sold = daily_category_sales.qty_sold + EXCLUDED.qty_sold,
+			revenue = daily_category_sales.revenue + EXCLUDED.revenue,
+			total_transactions = daily_category_sales.total_transactions + EXCLUDED.total_transactions
+	`, transactionID, zone)
+	return err
+}
+
+// RebuildDailySummaries recomputes every daily summary of a tenant from the
+// raw transactions. Run it after upgrading an existing database, after
+// changing a store's timezone or STORE_TIMEZONE, or whenever the summaries
+// are suspected to be wrong. Checkouts of the tenant wait while it runs.
+func RebuildDailySummaries(ctx context.Context, tenantID int) error {
+	zone, err := fallbackZone()
+	if err != nil {
+		return err
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Blocks concurrent checkouts from updating the summaries until the
+	// rebuild commits, so none of them is counted twice or lost.
+	_, err = tx.ExecContext(ctx, "LOCK TABLE daily_sales, daily_product_sales, daily_category_sales IN SHARE ROW EXCLUSIVE MODE")
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{"daily_sales", "daily_product_sales", "daily_category_sales"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE tenant_id = $1", tenantID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO daily_sales (tenant_id, store_id, sales_date, payment_method, total_revenue, total_transactions)
+		SELECT t.tenant_id, t.store_id, `+salesDate+`, t.payment_method, SUM(t.total_amount), COUNT(*)
+		FROM transactions t
+		JOIN stores s ON t.store_id = s.id
+		WHERE t.tenant_id = $1 AND t.status = 'completed'
+		GROUP BY t.tenant_id, t.store_id, 3, t.payment_method
+	`, tenantID, zone)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO daily_product_sales (tenant_id, store_id, sales_date, product_id, category_id, payment_method, qty_sold, revenue, total_transactions)
+		SELECT t.tenant_id, t.store_id, `+salesDate+`, td.product_id, td.category_id, t.payment_method, SUM(td.quantity), SUM(td.subtotal), COUNT(DISTINCT t.id)
+		FROM transaction_details td
+		JOIN transactions t ON td.transaction_id = t.id
+		JOIN stores s ON t.store_id = s.id
+		WHERE t.tenant_id = $1 AND t.status = 'completed'
+		GROUP BY t.tenant_id, t.store_id, 3, td.product_id, td.category_id, t.payment_method
+	`, tenantID, zone)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO daily_category_sales (tenant_id, store_id, sales_date, category_id, payment_method, qty_sold, revenue, total_transactions)
+		SELECT t.tenant_id, t.store_id, `+salesDate+`, td.category_id, t.payment_method, SUM(td.quantity), SUM(td.subtotal), COUNT(DISTINCT t.id)
+		FROM transaction_details td
+		JOIN transactions t ON td.transaction_id = t.id
+		JOIN stores s ON t.store_id = s.id
+		WHERE t.tenant_id = $1 AND t.status = 'completed'
+		GROUP BY t.tenant_id, t.store_id, 3, td.category_id, t.payment_method
+	`, tenantID, zone)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// salesPeriod splits a report range in two: whole local days before today,
+// read from the daily summary tables, and the remainder (today, or the whole
+// range when the summaries cannot be used), read from the raw transaction
+// tables. SummaryFrom and SummaryTo are NULL when no day is summarized;
+// RawFrom is after RawTo when nothing is read raw.
+type salesPeriod struct {
+	RawFrom     time.Time
+	RawTo       time.Time
+	SummaryFrom sql.NullString
+	SummaryTo   sql.NullString
+}
+
+// newSalesPeriod plans how the range between start and end is read. The
+// summaries are dated in each store's own timezone, so they are only used
+// when every store in the report shares the report's timezone.
+func newSalesPeriod(ctx context.Context, db *database.TenantDB, tenantID int, start, end time.Time, opts ReportOptions) (salesPeriod, error) {
+	period := salesPeriod{RawFrom: start, RawTo: end}
+	if opts.Timezone == "" {
+		return period, nil
+	}
+	loc, err := time.LoadLocation(opts.Timezone)
+	if err != nil {
+		return period, nil
+	}
+
+	localStart := start.In(loc)
+	if !localStart.Equal(time.Date(localStart.Year(), localStart.Month(), localStart.Day(), 0, 0, 0, 0, loc)) {
+		return period, nil
+	}
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	// The last summarized day is the last whole day of the range before today.
+	last := end.In(loc)
+	last = time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, loc)
+	if last.AddDate(0, 0, 1).Add(-time.Nanosecond).After(end) {
+		last = last.AddDate(0, 0, -1)
+	}
+	if !last.Before(today) {
+		last = today.AddDate(0, 0, -1)
+	}
+	if last.Before(localStart) {
+		return period, nil
+	}
+
+	zone, err := fallbackZone()
+	if err != nil {
+		return period, err
+	}
+	var mismatched int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM stores WHERE tenant_id = $1 AND ($2 = 0 OR id = $2) AND COALESCE(NULLIF(timezone, ''), $3) <> $4", tenantID, opts.StoreID, zone, loc.String()).Scan(&mismatched)
+	if err != nil {
+		return period, err
+	}
+	if mismatched > 0 {
+		return period, nil
+	}
+
+	period.SummaryFrom = sql.NullString{String: localStart.Format("2006-01-02"), Valid: true}
+	period.SummaryTo = sql.NullString{String: last.Format("2006-01-02"), Valid: true}
+	period.RawFrom = last.AddDate(0, 0, 1).UTC()
+	return period, nil
+}
+
+// productLines lists quantity and revenue per product line in a salesPeriod,
+// from raw details and daily summaries alike. Queries using it bind $1 tenant,
+// $2/$3 raw range, $4 store (0 for all) and $5/$6 summarized days.
+const productLines = `product_lines AS (
+			SELECT td.product_id, td.category_id, td.quantity AS qty_sold, td.subtotal AS revenue
+			FROM transaction_details td
+			JOIN transactions t ON td.transaction_id = t.id
+			WHERE t.tenant_id = $1 AND t.status = 'completed' AND t.created_at >= $2 AND t.created_at <= $3 AND ($4 = 0 OR t.store_id = $4)
+			UNION ALL
+			SELECT product_id, category_id, qty_sold, revenue
+			FROM daily_product_sales
+			WHERE tenant_id = $1 AND sales_date BETWEEN $5 AND $6 AND ($4 = 0 OR store_id = $4)
+		)`
+
+// salesTotals is the same for whole transactions, per store.
+const salesTotals = `sales_totals AS (
+			SELECT store_id, total_amount AS revenue, 1 AS transactions
+			FROM transactions
+			WHERE tenant_id = $1 AND status = 'completed' AND created_at >= $2 AND created_at <= $3 AND ($4 = 0 OR store_id = $4)
+			UNION ALL
+			SELECT store_id, total_revenue, total_transactions
+			FROM daily_sales
+			WHERE tenant_id = $1 AND sales_date BETWEEN $5 AND $6 AND ($4 = 0 OR store_id = $4)
+		)`
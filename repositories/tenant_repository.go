@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"categories-api/database"
+	"categories-api/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+)
+
+var ErrTenantInactive = errors.New("tenant is inactive")
+
+func GetAllTenants(ctx context.Context) ([]models.Tenant, error) {
+	db := database.GetDB()
+	rows, err := db.QueryContext(ctx, "SELECT id, name, active, created_at, updated_at FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []models.Tenant
+	for rows.Next() {
+		var t models.Tenant
+		if err := rows.Scan(&t.ID, &t.Name, &t.Active, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+func GetTenantByID(ctx context.Context, id int) (*models.Tenant, error) {
+	db := database.GetDB()
+	var t models.Tenant
+	err := db.QueryRowContext(ctx, "SELECT id, name, active, created_at, updated_at FROM tenants WHERE id = $1", id).Scan(&t.ID, &t.Name, &t.Active, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err, "tenant", id)
+	}
+	return &t, nil
+}
+
+// GetTenantIDByAPIKey resolves the tenant that owns an API key.
+func GetTenantIDByAPIKey(ctx context.Context, apiKey string) (int, error) {
+	db := database.GetDB()
+	var id int
+	var active bool
+	err := db.QueryRowContext(ctx, "SELECT id, active FROM tenants WHERE api_key_hash = $1", hashAPIKey(apiKey)).Scan(&id, &active)
+	if err != nil {
+		return 0, err
+	}
+	if !active {
+		return 0, ErrTenantInactive
+	}
+	return id, nil
+}
+
+// CreateTenant provisions a tenant together with its first store and returns
+// the freshly generated API key.
+func CreateTenant(ctx context.Context, req models.TenantRequest) (*models.TenantWithAPIKey, error) {
+	if req.Name == "" {
+		return nil, &ValidationError{Message: "name is required"}
+	}
+
+	apiKey, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var t models.Tenant
+	err = tx.QueryRowContext(ctx, "INSERT INTO tenants (name, api_key_hash, updated_at) VALUES ($1, $2, (now() AT TIME ZONE 'UTC')) RETURNING id, name, active, created_at, updated_at", req.Name, hashAPIKey(apiKey)).Scan(&t.ID, &t.Name, &t.Active, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scope the rest of the transaction to the new tenant so the insert passes
+	// the row-level security check when it is enabled.
+	_, err = tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", strconv.Itoa(t.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO stores (tenant_id, name, updated_at) VALUES ($1, $2, (now() AT TIME ZONE 'UTC'))", t.ID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &models.TenantWithAPIKey{Tenant: t, APIKey: apiKey}, nil
+}
+
+func UpdateTenant(ctx context.Context, id int, req models.TenantRequest) (*models.Tenant, error) {
+	db := database.GetDB()
+	var t models.Tenant
+	err := db.QueryRowContext(ctx, "UPDATE tenants SET name = COALESCE(NULLIF($1, ''), name), active = COALESCE($2, active), updated_at = (now() AT TIME ZONE 'UTC') WHERE id = $3 RETURNING id, name, active, created_at, updated_at", req.Name, req.Active, id).Scan(&t.ID, &t.Name, &t.Active, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err, "tenant", id)
+	}
+	return &t, nil
+}
+
+// RotateTenantAPIKey replaces the tenant's API key; the old key stops working
+// immediately.
+func RotateTenantAPIKey(ctx context.Context, id int) (*models.TenantWithAPIKey, error) {
+	apiKey, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+	var t models.Tenant
+	err = db.QueryRowContext(ctx, "UPDATE tenants SET api_key_hash = $1, updated_at = (now() AT TIME ZONE 'UTC') WHERE id = $2 RETURNING id, name, active, created_at, updated_at", hashAPIKey(apiKey), id).Scan(&t.ID, &t.Name, &t.Active, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err, "tenant", id)
+	}
+	return &models.TenantWithAPIKey{Tenant: t, APIKey: apiKey}, nil
+}
+
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,53 +3,66 @@ package repositories
 import (
 	"categories-api/database"
 	"categories-api/models"
-	"fmt"
+	"context"
+	"database/sql"
+	"time"
 )
 
-type ValidationError struct {
-	Message   string
-	ProductID int
-	Requested int
-	Available int
-}
+func CreateTransaction(ctx context.Context, tenantID int, req models.TransactionRequest) (*models.TransactionWithDetails, error) {
+	items := req.Items
 
-func (e *ValidationError) Error() string {
-	if e.ProductID > 0 {
-		return fmt.Sprintf("%s (product_id: %d, requested: %d, available: %d)", e.Message, e.ProductID, e.Requested, e.Available)
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
 	}
-	return e.Message
-}
+	defer db.Close()
 
-func CreateTransaction(items []models.TransactionItem) (*models.TransactionWithDetails, error) {
-	db := database.GetDB()
-	tx, err := db.Begin()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
+	defer tx.Rollback()
+
+	storeID := req.StoreID
+	if storeID == 0 {
+		storeID, err = defaultStoreID(ctx, tx, tenantID)
 		if err != nil {
-			tx.Rollback()
+			return nil, err
 		}
-	}()
+	}
+
+	if err = storeExists(ctx, tx, tenantID, storeID); err != nil {
+		return nil, err
+	}
 
 	var totalAmount int
 	var details []models.TransactionDetail
 
 	for _, item := range items {
 		var price int
-		var currentStock int
+		var archived bool
 
-		err := tx.QueryRow("SELECT price, stock FROM products WHERE id = $1 FOR UPDATE", item.ProductID).Scan(&price, &currentStock)
+		err := tx.QueryRowContext(ctx, "SELECT price, archived_at IS NOT NULL FROM products WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL FOR UPDATE", item.ProductID, tenantID).Scan(&price, &archived)
 		if err != nil {
 			return nil, &ValidationError{
 				Message:   "product not found",
 				ProductID: item.ProductID,
 			}
 		}
+		if archived {
+			return nil, &ValidationError{
+				Message:   "product is archived",
+				ProductID: item.ProductID,
+			}
+		}
+
+		currentStock, err := lockStoreStock(ctx, tx, storeID, item.ProductID)
+		if err != nil {
+			return nil, err
+		}
 
 		if currentStock < item.Quantity {
-			return nil, &ValidationError{
-				Message:   "insufficient stock for product",
+			return nil, &InsufficientStockError{
 				ProductID: item.ProductID,
 				Requested: item.Quantity,
 				Available: currentStock,
@@ -59,29 +72,42 @@ func CreateTransaction(items []models.TransactionItem) (*models.TransactionWithD
 		subtotal := price * item.Quantity
 		totalAmount += subtotal
 
-		_, err = tx.Exec("UPDATE products SET stock = stock - $1 WHERE id = $2", item.Quantity, item.ProductID)
+		err = adjustStock(ctx, tx, storeID, item.ProductID, -item.Quantity)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	paymentMethod := req.PaymentMethod
+	if paymentMethod == "" {
+		paymentMethod = "cash"
+	}
+
+	paidAmount := req.PaidAmount
+	if paidAmount == 0 {
+		paidAmount = totalAmount
+	}
+	if paidAmount < totalAmount {
+		return nil, &ValidationError{Message: "paid amount is less than total amount"}
+	}
+
 	var transactionID int
-	err = tx.QueryRow("INSERT INTO transactions (total_amount, status) VALUES ($1, 'completed') RETURNING id", totalAmount).Scan(&transactionID)
+	err = tx.QueryRowContext(ctx, "INSERT INTO transactions (tenant_id, store_id, total_amount, payment_method, paid_amount, change_amount, status) VALUES ($1, $2, $3, $4, $5, $6, 'completed') RETURNING id", tenantID, storeID, totalAmount, paymentMethod, paidAmount, paidAmount-totalAmount).Scan(&transactionID)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, item := range items {
 		var detailID int
-		var price int
-		err := tx.QueryRow("SELECT price FROM products WHERE id = $1", item.ProductID).Scan(&price)
+		var price, categoryID int
+		err := tx.QueryRowContext(ctx, "SELECT price, categories_id FROM products WHERE id = $1", item.ProductID).Scan(&price, &categoryID)
 		if err != nil {
 			return nil, err
 		}
 
 		subtotal := price * item.Quantity
 
-		err = tx.QueryRow("INSERT INTO transaction_details (transaction_id, product_id, quantity, subtotal) VALUES ($1, $2, $3, $4) RETURNING id", transactionID, item.ProductID, item.Quantity, subtotal).Scan(&detailID)
+		err = tx.QueryRowContext(ctx, "INSERT INTO transaction_details (transaction_id, product_id, category_id, quantity, subtotal) VALUES ($1, $2, $3, $4, $5) RETURNING id", transactionID, item.ProductID, categoryID, item.Quantity, subtotal).Scan(&detailID)
 		if err != nil {
 			return nil, err
 		}
@@ -95,12 +121,22 @@ func CreateTransaction(items []models.TransactionItem) (*models.TransactionWithD
 		})
 	}
 
+	if err = applyDailySummaries(ctx, tx, transactionID); err != nil {
+		return nil, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
 
-	transaction, err := GetTransactionByID(transactionID)
+	productIDs := make([]int, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	refreshSuggestions(ctx, tenantID, productIDs...)
+
+	transaction, err := GetTransactionByID(ctx, tenantID, transactionID)
 	if err != nil {
 		return nil, err
 	}
@@ -108,36 +144,44 @@ func CreateTransaction(items []models.TransactionItem) (*models.TransactionWithD
 	return transaction, nil
 }
 
-func GetAllTransactions() []models.Transaction {
-	db := database.GetDB()
-	rows, err := db.Query("SELECT id, total_amount, status, created_at FROM transactions ORDER BY id DESC")
+func GetAllTransactions(ctx context.Context, tenantID int) ([]models.Transaction, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, store_id, total_amount, payment_method, paid_amount, change_amount, status, created_at FROM transactions WHERE tenant_id = $1 ORDER BY id DESC", tenantID)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer rows.Close()
 
 	var transactions []models.Transaction
 	for rows.Next() {
 		var t models.Transaction
-		err := rows.Scan(&t.ID, &t.TotalAmount, &t.Status, &t.CreatedAt)
-		if err != nil {
-			continue
+		if err := rows.Scan(&t.ID, &t.StoreID, &t.TotalAmount, &t.PaymentMethod, &t.PaidAmount, &t.ChangeAmount, &t.Status, &t.CreatedAt); err != nil {
+			return nil, err
 		}
 		transactions = append(transactions, t)
 	}
-	return transactions
+	return transactions, rows.Err()
 }
 
-func GetTransactionByID(id int) (*models.TransactionWithDetails, error) {
-	db := database.GetDB()
+func GetTransactionByID(ctx context.Context, tenantID, id int) (*models.TransactionWithDetails, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
 
 	var transaction models.Transaction
-	err := db.QueryRow("SELECT id, total_amount, status, created_at FROM transactions WHERE id = $1", id).Scan(&transaction.ID, &transaction.TotalAmount, &transaction.Status, &transaction.CreatedAt)
+	err = db.QueryRowContext(ctx, "SELECT id, store_id, total_amount, payment_method, paid_amount, change_amount, status, created_at FROM transactions WHERE id = $1 AND tenant_id = $2", id, tenantID).Scan(&transaction.ID, &transaction.StoreID, &transaction.TotalAmount, &transaction.PaymentMethod, &transaction.PaidAmount, &transaction.ChangeAmount, &transaction.Status, &transaction.CreatedAt)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "transaction", id)
 	}
 
-	rows, err := db.Query("SELECT id, transaction_id, product_id, quantity, subtotal FROM transaction_details WHERE transaction_id = $1", id)
+	rows, err := db.QueryContext(ctx, "SELECT id, transaction_id, product_id, quantity, subtotal FROM transaction_details WHERE transaction_id = $1", id)
 	if err != nil {
 		return nil, err
 	}
@@ -146,15 +190,188 @@ func GetTransactionByID(id int) (*models.TransactionWithDetails, error) {
 	var details []models.TransactionDetail
 	for rows.Next() {
 		var d models.TransactionDetail
-		err := rows.Scan(&d.ID, &d.TransactionID, &d.ProductID, &d.Quantity, &d.Subtotal)
-		if err != nil {
-			continue
+		if err := rows.Scan(&d.ID, &d.TransactionID, &d.ProductID, &d.Quantity, &d.Subtotal); err != nil {
+			return nil, err
 		}
 		details = append(details, d)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return &models.TransactionWithDetails{
 		Transaction: transaction,
 		Details:     details,
 	}, nil
 }
+
+func GetReceiptByTransactionID(ctx context.Context, tenantID, id int) (*models.Receipt, error) {
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var receipt models.Receipt
+	var timezone string
+	err = db.QueryRowContext(ctx, `
+		SELECT t.id, t.total_amount, t.payment_method, t.paid_amount, t.change_amount, t.created_at,
+			s.name, COALESCE(s.address, ''), COALESCE(s.phone, ''), s.timezone
+		FROM transactions t
+		JOIN stores s ON t.store_id = s.id
+		WHERE t.id = $1 AND t.tenant_id = $2
+	`, id, tenantID).Scan(&receipt.TransactionID, &receipt.TotalAmount, &receipt.PaymentMethod, &receipt.PaidAmount, &receipt.ChangeAmount, &receipt.CreatedAt, &receipt.StoreName, &receipt.StoreAddress, &receipt.StorePhone, &timezone)
+	if err != nil {
+		return nil, notFound(err, "transaction", id)
+	}
+
+	loc, err := StoreLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	receipt.CreatedAt = receipt.CreatedAt.In(loc)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT td.product_id, p.name, td.quantity, td.subtotal
+		FROM transaction_details td
+		JOIN products p ON td.product_id = p.id
+		WHERE td.transaction_id = $1
+		ORDER BY td.id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.ReceiptItem
+		if err := rows.Scan(&item.ProductID, &item.Name, &item.Quantity, &item.Subtotal); err != nil {
+			return nil, err
+		}
+		if item.Quantity > 0 {
+			item.Price = item.Subtotal / item.Quantity
+		}
+		receipt.Items = append(receipt.Items, item)
+	}
+
+	return &receipt, rows.Err()
+}
+
+// TransactionFilter limits a transaction export. StartDate and EndDate are
+// YYYY-MM-DD local days and must be given together; without them every
+// transaction is exported. StoreID 0 means all stores.
+type TransactionFilter struct {
+	StartDate string
+	EndDate   string
+	StoreID   int
+	Timezone  string
+}
+
+// transactionRange resolves the filter's dates to timestamps in the store's
+// timezone. Both bounds are NULL when no dates are given.
+func transactionRange(ctx context.Context, tenantID int, filter TransactionFilter) (sql.NullTime, sql.NullTime, *time.Location, error) {
+	loc, err := reportLocation(ctx, tenantID, ReportOptions{StoreID: filter.StoreID, Timezone: filter.Timezone})
+	if err != nil {
+		return sql.NullTime{}, sql.NullTime{}, nil, err
+	}
+	if filter.StartDate == "" && filter.EndDate == "" {
+		return sql.NullTime{}, sql.NullTime{}, loc, nil
+	}
+	if filter.StartDate == "" || filter.EndDate == "" {
+		return sql.NullTime{}, sql.NullTime{}, nil, &ValidationError{Message: "start_date and end_date must be given together"}
+	}
+
+	start, end, err := parseDateRange(filter.StartDate, filter.EndDate, loc)
+	if err != nil {
+		return sql.NullTime{}, sql.NullTime{}, nil, err
+	}
+	return sql.NullTime{Time: start, Valid: true}, sql.NullTime{Time: end, Valid: true}, loc, nil
+}
+
+// StreamTransactions calls fn for every transaction matching the filter,
+// oldest first, reading rows from the database cursor one at a time so large
+// exports are never held in memory. Iteration stops at the first error fn
+// returns.
+func StreamTransactions(ctx context.Context, tenantID int, filter TransactionFilter, fn func(models.Transaction) error) error {
+	start, end, loc, err := transactionRange(ctx, tenantID, filter)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, store_id, total_amount, payment_method, paid_amount, change_amount, status, created_at
+		FROM transactions
+		WHERE tenant_id = $1 AND ($2::timestamp IS NULL OR created_at >= $2) AND ($3::timestamp IS NULL OR created_at <= $3) AND ($4 = 0 OR store_id = $4)
+		ORDER BY id
+	`, tenantID, start, end, filter.StoreID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Transaction
+		err := rows.Scan(&t.ID, &t.StoreID, &t.TotalAmount, &t.PaymentMethod, &t.PaidAmount, &t.ChangeAmount, &t.Status, &t.CreatedAt)
+		if err != nil {
+			return err
+		}
+		t.CreatedAt = t.CreatedAt.In(loc)
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamTransactionLines calls fn for every item sold in completed
+// transactions matching the filter, streaming like StreamTransactions.
+func StreamTransactionLines(ctx context.Context, tenantID int, filter TransactionFilter, fn func(models.TransactionLine) error) error {
+	start, end, loc, err := transactionRange(ctx, tenantID, filter)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.ForTenant(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT t.id, t.created_at, t.store_id, s.name, t.payment_method,
+			td.product_id, p.name, COALESCE(c.name, ''), td.quantity, td.subtotal
+		FROM transaction_details td
+		JOIN transactions t ON td.transaction_id = t.id
+		JOIN stores s ON t.store_id = s.id
+		JOIN products p ON td.product_id = p.id
+		LEFT JOIN categories c ON td.category_id = c.id
+		WHERE t.tenant_id = $1 AND t.status = 'completed' AND ($2::timestamp IS NULL OR t.created_at >= $2) AND ($3::timestamp IS NULL OR t.created_at <= $3) AND ($4 = 0 OR t.store_id = $4)
+		ORDER BY t.id, td.id
+	`, tenantID, start, end, filter.StoreID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l models.TransactionLine
+		err := rows.Scan(&l.TransactionID, &l.CreatedAt, &l.StoreID, &l.StoreName, &l.PaymentMethod, &l.ProductID, &l.ProductName, &l.CategoryName, &l.Quantity, &l.Subtotal)
+		if err != nil {
+			return err
+		}
+		l.CreatedAt = l.CreatedAt.In(loc)
+		if l.Quantity > 0 {
+			l.Price = l.Subtotal / l.Quantity
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
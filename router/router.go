@@ -0,0 +1,203 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"categories-api/handlers"
+	"categories-api/models"
+	"categories-api/utils"
+)
+
+// Router dispatches requests by method and path pattern. Unknown paths get
+// a JSON 404 and known paths with the wrong method a JSON 405 carrying the
+// Allow header.
+type Router struct {
+	mux *http.ServeMux
+}
+
+// QueryTimeouts bound the database work of a request, by route. Lookup is
+// for single records a POS client polls, Long for reports, transaction
+// lists, imports and bulk changes, Export for CSV/XLSX downloads, which
+// stream for as long as the data takes, and Default for everything else.
+// Zero means no limit.
+type QueryTimeouts struct {
+	Lookup  time.Duration
+	Default time.Duration
+	Long    time.Duration
+	Export  time.Duration
+}
+
+// New registers every route of the API.
+func New(timeouts QueryTimeouts) *Router {
+	mux := http.NewServeMux()
+	lookup := withQueryTimeout(mux, timeouts.Lookup)
+	route := withQueryTimeout(mux, timeouts.Default)
+	long := withQueryTimeout(mux, timeouts.Long)
+	export := withQueryTimeout(mux, timeouts.Export)
+	// Routes answering in JSON or, on request, as an export.
+	longOrExport := withExportTimeout(mux, timeouts.Long, timeouts.Export)
+
+	route("GET /categories", handlers.ListCategoriesHandler)
+	route("POST /categories", handlers.CreateCategoryHandler)
+	long("DELETE /categories/bulk", handlers.BulkDeleteCategoriesHandler)
+	lookup("GET /categories/{id}", handlers.GetCategoryHandler)
+	route("PUT /categories/{id}", handlers.UpdateCategoryHandler)
+	route("PATCH /categories/{id}", handlers.PatchCategoryHandler)
+	route("DELETE /categories/{id}", handlers.DeleteCategoryHandler)
+	route("POST /categories/{id}/restore", handlers.RestoreCategoryHandler)
+	route("GET /categories/{id}/products", handlers.CategoryProductsHandler)
+
+	route("GET /products", handlers.ListProductsHandler)
+	route("POST /products", handlers.CreateProductHandler)
+	long("POST /products/import", handlers.ProductImportHandler)
+	long("PATCH /products/bulk", handlers.BulkUpdateProductsHandler)
+	long("DELETE /products/bulk", handlers.BulkDeleteProductsHandler)
+	lookup("GET /products/suggest", handlers.ProductSuggestHandler)
+	lookup("GET /products/{id}", handlers.GetProductHandler)
+	route("PUT /products/{id}", handlers.UpdateProductHandler)
+	route("PATCH /products/{id}", handlers.PatchProductHandler)
+	route("DELETE /products/{id}", handlers.DeleteProductHandler)
+	route("POST /products/{id}/restore", handlers.RestoreProductHandler)
+	route("POST /products/{id}/archive", handlers.ArchiveProductHandler)
+	route("POST /products/{id}/unarchive", handlers.UnarchiveProductHandler)
+
+	longOrExport("GET /transactions", handlers.ListTransactionsHandler)
+	route("POST /transactions", handlers.CreateTransactionHandler)
+	export("GET /transactions/lines", handlers.TransactionLinesHandler)
+	lookup("GET /transactions/{id}", handlers.GetTransactionHandler)
+	route("GET /transactions/{id}/receipt", handlers.TransactionReceiptHandler)
+
+	route("GET /stores", handlers.ListStoresHandler)
+	route("POST /stores", handlers.CreateStoreHandler)
+	lookup("GET /stores/{id}", handlers.GetStoreHandler)
+	route("PUT /stores/{id}", handlers.UpdateStoreHandler)
+	route("DELETE /stores/{id}", handlers.DeleteStoreHandler)
+	route("GET /stores/{id}/stock", handlers.StoreStockHandler)
+	route("PUT /stores/{id}/stock/{product_id}", handlers.SetStoreStockHandler)
+
+	route("GET /transfers", handlers.ListTransfersHandler)
+	route("POST /transfers", handlers.CreateTransferHandler)
+	lookup("GET /transfers/{id}", handlers.GetTransferHandler)
+	route("POST /transfers/{id}/receive", handlers.ReceiveTransferHandler)
+	route("POST /transfers/{id}/cancel", handlers.CancelTransferHandler)
+
+	route("GET /tenants", handlers.ListTenantsHandler)
+	route("POST /tenants", handlers.CreateTenantHandler)
+	route("GET /tenants/{id}", handlers.GetTenantHandler)
+	route("PUT /tenants/{id}", handlers.UpdateTenantHandler)
+	route("POST /tenants/{id}/rotate-key", handlers.RotateTenantKeyHandler)
+
+	longOrExport("GET /api/report", handlers.DateRangeReportHandler)
+	long("GET /api/report/hari-ini", handlers.TodayReportHandler)
+	long("GET /api/report/sales", handlers.SalesReportHandler)
+	long("GET /api/report/products", handlers.ProductSalesReportHandler)
+	long("GET /api/report/categories", handlers.CategorySalesReportHandler)
+	long("GET /api/report/slow-movers", handlers.SlowMoversReportHandler)
+	long("GET /api/report/compare", handlers.ReportComparisonHandler)
+	long("GET /api/report/inventory", handlers.InventoryReportHandler)
+	long("GET /api/report/snapshots/{date}", handlers.ReportSnapshotsHandler)
+	long("POST /api/report/snapshots/{date}", handlers.CreateReportSnapshotsHandler)
+
+	mux.HandleFunc("GET /swagger", swaggerUI)
+	mux.HandleFunc("GET /swagger/", swaggerUI)
+	mux.HandleFunc("GET /swagger/doc.json", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "docs/swagger.json")
+	})
+
+	return &Router{mux: mux}
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern != "" {
+		rt.mux.ServeHTTP(w, r)
+		return
+	}
+
+	// No route matched. Let the mux decide between 404 and 405 (it knows
+	// which methods the path allows) and answer in JSON instead of its
+	// plain-text body.
+	var rec statusRecorder
+	rt.mux.ServeHTTP(&rec, r)
+
+	if rec.status == http.StatusMethodNotAllowed {
+		w.Header().Set("Allow", rec.Header().Get("Allow"))
+		utils.WriteError(w, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	utils.WriteError(w, http.StatusNotFound, models.ErrCodeNotFound, "not found", nil)
+}
+
+// withQueryTimeout returns a function registering handlers whose request
+// context expires after d. Repositories run their queries under that
+// context, so a slow query is cancelled in Postgres instead of running on
+// after the response.
+func withQueryTimeout(mux *http.ServeMux, d time.Duration) func(pattern string, handler http.HandlerFunc) {
+	return func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, queryTimeout(handler, func(*http.Request) time.Duration {
+			return d
+		}))
+	}
+}
+
+// withExportTimeout is withQueryTimeout for routes that answer in JSON or,
+// when the request asks for CSV or XLSX, with an export. Exports get the
+// export timeout instead of d, so a large download is not cut off halfway.
+func withExportTimeout(mux *http.ServeMux, d, export time.Duration) func(pattern string, handler http.HandlerFunc) {
+	return func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, queryTimeout(handler, func(r *http.Request) time.Duration {
+			if handlers.IsExport(r) {
+				return export
+			}
+			return d
+		}))
+	}
+}
+
+// queryTimeout runs handler with a request context that expires after the
+// duration timeout picks for the request, or without a limit when it is
+// zero.
+func queryTimeout(handler http.HandlerFunc, timeout func(*http.Request) time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := timeout(r)
+		if d <= 0 {
+			handler(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		handler(w, r.WithContext(ctx))
+	}
+}
+
+func swaggerUI(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "docs/index.html")
+}
+
+// statusRecorder captures the status and headers of the mux's own 404/405
+// response and discards its body.
+type statusRecorder struct {
+	header http.Header
+	status int
+}
+
+func (s *statusRecorder) Header() http.Header {
+	if s.header == nil {
+		s.header = http.Header{}
+	}
+	return s.header
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+}
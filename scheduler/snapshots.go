@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"categories-api/repositories"
+)
+
+// snapshotInterval is how often stores are checked for a due snapshot.
+const snapshotInterval = time.Minute
+
+// StartEndOfDaySnapshots snapshots the DailyReport of every store of every
+// active tenant once a day, as soon as the store's local time passes
+// closingTime (HH:MM). A store that already has a snapshot for its local
+// date is skipped, so restarting the server does not take a second one.
+//
+// The scheduler stops when ctx is cancelled; the returned channel is closed
+// once a snapshot in progress has finished, so the database can be closed.
+func StartEndOfDaySnapshots(ctx context.Context, closingTime string) (<-chan struct{}, error) {
+	closing, err := time.Parse("15:04", closingTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid closing time %q, use HH:MM", closingTime)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		done := map[string]bool{}
+		ticker := time.NewTicker(snapshotInterval)
+		defer ticker.Stop()
+
+		// Stopping waits for a snapshot in progress instead of cancelling
+		// its queries.
+		queryCtx := context.WithoutCancel(ctx)
+		for {
+			takeDueSnapshots(queryCtx, time.Now(), closing, done)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return stopped, nil
+}
+
+func takeDueSnapshots(ctx context.Context, now, closing time.Time, done map[string]bool) {
+	tenants, err := repositories.GetAllTenants(ctx)
+	if err != nil {
+		log.Printf("snapshot: list tenants: %v", err)
+		return
+	}
+	for _, tenant := range tenants {
+		if !tenant.Active {
+			continue
+		}
+		stores, err := repositories.GetAllStores(ctx, tenant.ID)
+		if err != nil {
+			log.Printf("snapshot: tenant %d: list stores: %v", tenant.ID, err)
+			continue
+		}
+		for _, store := range stores {
+			loc, err := repositories.StoreLocation(store.Timezone)
+			if err != nil {
+				log.Printf("snapshot: store %d: %v", store.ID, err)
+				continue
+			}
+
+			local := now.In(loc)
+			closingAt := time.Date(local.Year(), local.Month(), local.Day(), closing.Hour(), closing.Minute(), 0, 0, loc)
+			if local.Before(closingAt) {
+				continue
+			}
+
+			date := local.Format("2006-01-02")
+			key := strconv.Itoa(store.ID) + "/" + date
+			if done[key] {
+				continue
+			}
+
+			exists, err := repositories.HasReportSnapshot(ctx, tenant.ID, store.ID, date)
+			if err != nil {
+				log.Printf("snapshot: store %d: %v", store.ID, err)
+				continue
+			}
+			if !exists {
+				if _, _, err := repositories.CreateReportSnapshot(ctx, tenant.ID, store.ID, date); err != nil {
+					log.Printf("snapshot: store %d: %v", store.ID, err)
+					continue
+				}
+				log.Printf("snapshot: stored end-of-day report of store %d for %s", store.ID, date)
+			}
+			done[key] = true
+		}
+	}
+
+	// Keys of past days are no longer needed.
+	cutoff := now.AddDate(0, 0, -2).Format("2006-01-02")
+	for key := range done {
+		if key[len(key)-10:] < cutoff {
+			delete(done, key)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"categories-api/models"
+)
+
+// WriteError writes the JSON error envelope. The request ID is copied from
+// the X-Request-ID response header set by the request ID middleware.
+func WriteError(w http.ResponseWriter, status int, code, message string, details any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.ErrorResponse{Error: models.APIError{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: w.Header().Get("X-Request-ID"),
+	}})
+}
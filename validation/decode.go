@@ -0,0 +1,94 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MaxBodySize caps JSON request bodies. File uploads such as the product
+// import have their own limit.
+const MaxBodySize = 1 << 20
+
+// ErrBodyTooLarge is returned by Decode for bodies over MaxBodySize.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// Decode reads a single JSON value from the request body into v and then
+// validates it. Unknown fields, trailing data and bodies over MaxBodySize
+// are rejected. Problems with the JSON itself and rule violations are both
+// returned as Errors.
+func Decode(w http.ResponseWriter, r *http.Request, v any) error {
+	if err := decodeSingle(w, r, v); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+// decodeSingle is Decode without the validation.
+func decodeSingle(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if dec.More() {
+		return Errors{{Message: "request body must contain a single JSON value"}}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return ErrBodyTooLarge
+		}
+		return Errors{{Message: "request body must contain a single JSON value"}}
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var (
+		maxErr    *http.MaxBytesError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxErr):
+		return ErrBodyTooLarge
+	case errors.Is(err, io.EOF):
+		return Errors{{Message: "request body is required"}}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return Errors{{Message: "malformed JSON: unexpected end of body"}}
+	case errors.As(err, &syntaxErr):
+		return Errors{{Message: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset)}}
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return Errors{{Message: "request body must be a JSON " + typeErr.Type.Kind().String()}}
+		}
+		return Errors{{Field: typeErr.Field, Message: "must be " + jsonType(typeErr.Type.Kind().String())}}
+	}
+
+	// encoding/json has no typed error for unknown fields.
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return Errors{{Field: strings.Trim(field, `"`), Message: "is not a known field"}}
+	}
+	return Errors{{Message: "malformed JSON: " + err.Error()}}
+}
+
+// jsonType names a Go kind the way a JSON client thinks of it.
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "true or false"
+	case kind == "slice", kind == "array":
+		return "an array"
+	default:
+		return "an object"
+	}
+}
@@ -0,0 +1,112 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// Patch is a JSON merge patch (RFC 7396) read by DecodePatch: the fields to
+// change, with null for fields to clear.
+type Patch map[string]json.RawMessage
+
+// DecodePatch reads a merge patch from the request body. The body must be a
+// single JSON object no larger than MaxBodySize.
+func DecodePatch(w http.ResponseWriter, r *http.Request) (Patch, error) {
+	var raw json.RawMessage
+	if err := decodeSingle(w, r, &raw); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		return nil, Errors{{Message: "merge patch must be a JSON object"}}
+	}
+	var patch Patch
+	if err := json.Unmarshal(raw, &patch); err != nil {
+		return nil, decodeError(err)
+	}
+	return patch, nil
+}
+
+// Has reports whether the patch sets or clears field.
+func (p Patch) Has(field string) bool {
+	_, ok := p[field]
+	return ok
+}
+
+// Restrict returns Errors naming the fields of the patch that are not in
+// writable, such as id or version, or nil when it only touches writable
+// fields.
+func (p Patch) Restrict(writable ...string) error {
+	allowed := make(map[string]bool, len(writable))
+	for _, field := range writable {
+		allowed[field] = true
+	}
+	var errs Errors
+	for field := range p {
+		if !allowed[field] {
+			errs = append(errs, FieldError{Field: field, Message: "cannot be changed"})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Apply merges the patch into v, a pointer to the current state of the
+// record, and validates the result. Unknown fields are rejected as in
+// Decode; a cleared field takes its zero value.
+func (p Patch) Apply(v any) error {
+	current, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err = json.Unmarshal(current, &doc); err != nil {
+		return err
+	}
+
+	var patch any
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(raw, &patch); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return err
+	}
+	// Start from zero values so that cleared fields do not keep their old
+	// value.
+	reflect.ValueOf(v).Elem().SetZero()
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	return Validate(v)
+}
+
+// mergePatch is the MergePatch function of RFC 7396 section 2.
+func mergePatch(target, patch any) any {
+	fields, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]any)
+	if !ok {
+		doc = map[string]any{}
+	}
+	for name, value := range fields {
+		if value == nil {
+			delete(doc, name)
+		} else {
+			doc[name] = mergePatch(doc[name], value)
+		}
+	}
+	return doc
+}
@@ -0,0 +1,187 @@
+// Package validation decodes JSON request bodies strictly and checks them
+// against the rules declared on the models in `validate` struct tags:
+//
+//	Name  string `json:"name" validate:"required,max=255"`
+//	Price int    `json:"price" validate:"min=1"`
+//
+// required rejects zero numbers, blank strings, empty slices and nil
+// pointers. min and max bound numbers by value and strings and slices by
+// length. Nested structs and slices of structs are checked too; errors name
+// the field by its JSON path, e.g. items[0].quantity.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes one invalid field. Field is empty for problems with
+// the body as a whole, such as malformed JSON.
+type FieldError struct {
+	Field   string `json:"field,omitempty" example:"items[0].quantity"`
+	Message string `json:"message" example:"must be at least 1"`
+}
+
+// Errors lists every invalid field of a request body.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, f := range e {
+		if f.Field == "" {
+			parts[i] = f.Message
+		} else {
+			parts[i] = f.Field + " " + f.Message
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Validate checks v, a struct or pointer to struct, against its rules. It
+// returns nil when everything is valid.
+func Validate(v any) error {
+	var errs Errors
+	validateStruct(reflect.Indirect(reflect.ValueOf(v)), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *Errors) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous {
+			validateStruct(reflect.Indirect(v.Field(i)), prefix, errs)
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		validateField(v.Field(i), prefix+name, field.Tag.Get("validate"), errs)
+	}
+}
+
+func validateField(v reflect.Value, path, rules string, errs *Errors) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			if hasRule(rules, "required") {
+				*errs = append(*errs, FieldError{Field: path, Message: "is required"})
+			}
+			return
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range splitRules(rules) {
+		name, arg, _ := strings.Cut(rule, "=")
+		msg := check(v, name, arg)
+		if msg != "" {
+			*errs = append(*errs, FieldError{Field: path, Message: msg})
+			// A missing value would only repeat itself in the other rules.
+			if name == "required" {
+				return
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		validateStruct(v, path+".", errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			elem := reflect.Indirect(v.Index(i))
+			if elem.Kind() == reflect.Struct {
+				validateStruct(elem, fmt.Sprintf("%s[%d].", path, i), errs)
+			}
+		}
+	}
+}
+
+// check applies one rule and returns the error message, or "" when the value
+// passes.
+func check(v reflect.Value, rule, arg string) string {
+	switch rule {
+	case "required":
+		if v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "" || v.IsZero() || isEmptySlice(v) {
+			return "is required"
+		}
+		return ""
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validation: bad %s argument %q", rule, arg))
+		}
+		return checkBound(v, rule, limit, arg)
+	default:
+		panic(fmt.Sprintf("validation: unknown rule %q", rule))
+	}
+}
+
+func checkBound(v reflect.Value, rule string, limit float64, arg string) string {
+	var n float64
+	var unit string
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	default:
+		return ""
+	}
+
+	if rule == "min" && n < limit {
+		if unit != "" {
+			return "must have at least " + arg + unit
+		}
+		return "must be at least " + arg
+	}
+	if rule == "max" && n > limit {
+		if unit != "" {
+			return "must have at most " + arg + unit
+		}
+		return "must be at most " + arg
+	}
+	return ""
+}
+
+func isEmptySlice(v reflect.Value) bool {
+	return (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0
+}
+
+func hasRule(rules, name string) bool {
+	for _, rule := range splitRules(rules) {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+func splitRules(rules string) []string {
+	if rules == "" {
+		return nil
+	}
+	return strings.Split(rules, ",")
+}